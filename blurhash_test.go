@@ -0,0 +1,20 @@
+package gomatrix
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestBlurhash(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 32, 24))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.NRGBA{255, 0, 0, 255}), image.Point{}, draw.Src)
+
+	// size flag 3+2*9 ("L") and DC 0xFF0000 ("TI:j" in base 83). The AC components aren't quite zero, as the
+	// cosines are sampled at the pixels' top left corners, as in the reference implementation.
+	want := "LDTI:j]9fQ]9|co1fQo1fQfQfQfQ"
+	if got := blurhash(img, 4, 3); got != want {
+		t.Fatalf("blurhash: got %s, want %s", got, want)
+	}
+}
@@ -5,10 +5,13 @@ package gomatrix
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"path"
@@ -17,27 +20,50 @@ import (
 	"time"
 
 	"github.com/rbns/gomatrix/event"
-	"github.com/rbns/gomatrix/response"
 	"github.com/rbns/gomatrix/request"
+	"github.com/rbns/gomatrix/response"
 )
 
 // Client represents a Matrix client.
 type Client struct {
-	HomeserverURL *url.URL     // The base homeserver URL
-	Prefix        string       // The API prefix eg '/_matrix/client/r0'
-	UserID        string       // The user ID of the client. Used for forming HTTP paths which use the client's user ID.
-	AccessToken   string       // The access_token for the client.
-	Client        *http.Client // The underlying HTTP client which will be used to make HTTP requests.
-	Syncer        Syncer       // The thing which can process /sync responses
-	Store         Storer       // The thing which can store rooms/tokens/ids
+	HomeserverURL     *url.URL     // The base homeserver URL
+	IdentityServerURL *url.URL     // The base identity server URL, if known. See DiscoverClientAPI.
+	Prefix            string       // The API prefix eg '/_matrix/client/r0'
+	UserID            string       // The user ID of the client. Used for forming HTTP paths which use the client's user ID.
+	AccessToken       string       // The access_token for the client. Use Tokens to read it while requests are in flight.
+	Client            *http.Client // The underlying HTTP client which will be used to make HTTP requests.
+	Syncer            Syncer       // The thing which can process /sync responses
+	Store             Storer       // The thing which can store rooms/tokens/ids
+	RetryPolicy       *RetryPolicy // How rate-limited requests are retried. If nil, they are not retried.
+
+	// Download media through the authenticated /_matrix/client/v1/media endpoints instead of /_matrix/media/v3.
+	// Homeservers which support Matrix 1.11 or later may refuse unauthenticated downloads of new media.
+	AuthenticatedMedia bool
 
 	// The ?user_id= query parameter for application services. This must be set *prior* to calling a method. If this is empty,
 	// no user_id parameter will be sent.
 	// See http://matrix.org/docs/spec/application_service/unstable.html#identity-assertion
 	AppServiceUserID string
 
+	// The refresh_token for the client, if the homeserver issued one. If this is set, requests which fail because the
+	// access token has expired are retried after refreshing it. See RefreshTokens.
+	//
+	// A refresh replaces AccessToken and RefreshToken from the goroutine of the request which hit the expiry, so
+	// they must not be read directly while requests are in flight: use Tokens or OnTokensRefreshed instead.
+	RefreshToken string
+	// OnTokensRefreshed is called, if set, whenever the access token has been refreshed. The refresh token is rotated
+	// at the same time, so both should be persisted.
+	OnTokensRefreshed func(resp *response.Refresh)
+
 	syncingMutex sync.Mutex // protects syncingID
 	syncingID    uint32     // Identifies the current Sync. Only one Sync can be active at any given time.
+
+	refreshMutex sync.Mutex   // Only one refresh can be in flight at any given time.
+	tokenMutex   sync.RWMutex // protects AccessToken and RefreshToken while they are refreshed
+
+	mediaConfigMutex   sync.Mutex            // protects mediaConfig and mediaConfigRetryAt
+	mediaConfig        *response.MediaConfig // The content repository config, fetched on the first upload
+	mediaConfigRetryAt time.Time             // When to fetch the config again, after it couldn't be fetched
 }
 
 // HTTPError An HTTP Error response, which may wrap an underlying native Go Error.
@@ -55,6 +81,28 @@ func (e HTTPError) Error() string {
 	return fmt.Sprintf("msg=%s code=%d wrapped=%s", e.Message, e.Code, wrappedErrMsg)
 }
 
+// Unwrap returns the wrapped error, so that errors.Is and errors.As can inspect the response.Error
+// returned by the homeserver.
+func (e HTTPError) Unwrap() error {
+	return e.WrappedError
+}
+
+// IsSoftLogout returns true if err means that the access token is no longer valid, but the homeserver has kept the
+// session: the client should log in again with the same device ID, keeping its encryption keys. If the Client has
+// a RefreshToken, this is handled by refreshing the token instead.
+// See https://spec.matrix.org/latest/client-server-api/#soft-logout
+func IsSoftLogout(err error) bool {
+	var respErr response.Error
+	return errors.As(err, &respErr) && respErr.Is(response.ErrUnknownToken) && respErr.SoftLogout
+}
+
+// IsHardLogout returns true if err means that the access token is no longer valid and the session has been
+// destroyed, e.g. because the device was deleted. The client should discard its data and log in as a new device.
+func IsHardLogout(err error) bool {
+	var respErr response.Error
+	return errors.As(err, &respErr) && respErr.Is(response.ErrUnknownToken) && !respErr.SoftLogout
+}
+
 // BuildURL builds a URL with the Client's homserver/prefix/access_token set already.
 func (cli *Client) BuildURL(urlPath ...string) string {
 	ps := []string{cli.Prefix}
@@ -73,8 +121,8 @@ func (cli *Client) BuildBaseURL(urlPath ...string) string {
 	parts = append(parts, urlPath...)
 	hsURL.Path = path.Join(parts...)
 	query := hsURL.Query()
-	if cli.AccessToken != "" {
-		query.Set("access_token", cli.AccessToken)
+	if accessToken := cli.accessToken(); accessToken != "" {
+		query.Set("access_token", accessToken)
 	}
 	if cli.AppServiceUserID != "" {
 		query.Set("user_id", cli.AppServiceUserID)
@@ -96,16 +144,35 @@ func (cli *Client) BuildURLWithQuery(urlPath []string, urlQuery map[string]strin
 
 // SetCredentials sets the user ID and access token on this client instance.
 func (cli *Client) SetCredentials(userID, accessToken string) {
+	cli.tokenMutex.Lock()
 	cli.AccessToken = accessToken
+	cli.tokenMutex.Unlock()
 	cli.UserID = userID
 }
 
 // ClearCredentials removes the user ID and access token on this client instance.
 func (cli *Client) ClearCredentials() {
+	cli.tokenMutex.Lock()
 	cli.AccessToken = ""
+	cli.tokenMutex.Unlock()
 	cli.UserID = ""
 }
 
+// Tokens returns the current access token and refresh token. Unlike reading the AccessToken and RefreshToken fields,
+// it is safe to call while requests which may refresh the tokens are in flight.
+func (cli *Client) Tokens() (accessToken, refreshToken string) {
+	cli.tokenMutex.RLock()
+	defer cli.tokenMutex.RUnlock()
+	return cli.AccessToken, cli.RefreshToken
+}
+
+// accessToken returns the current access token. It may be swapped by a refresh from another goroutine.
+func (cli *Client) accessToken() string {
+	cli.tokenMutex.RLock()
+	defer cli.tokenMutex.RUnlock()
+	return cli.AccessToken
+}
+
 // Sync starts syncing with the provided Homeserver. If Sync() is called twice then the first sync will be stopped and the
 // error will be nil.
 //
@@ -114,8 +181,15 @@ func (cli *Client) ClearCredentials() {
 //   - The failure to create a filter.
 //   - Client.Syncer.OnFailedSync returning an error in response to a failed sync.
 //   - Client.Syncer.ProcessResponse returning an error.
+//
 // If you wish to continue retrying in spite of these fatal errors, call Sync() again.
 func (cli *Client) Sync() error {
+	return cli.SyncContext(context.Background())
+}
+
+// SyncContext is like Sync, but stops syncing as soon as ctx is done. The in-flight /sync long-poll is aborted
+// and ctx.Err() is returned, so callers can shut down without waiting for the /sync timeout or calling StopSync.
+func (cli *Client) SyncContext(ctx context.Context) error {
 	// Mark the client as syncing.
 	// We will keep syncing until the syncing state changes. Either because
 	// Sync is called or StopSync is called.
@@ -124,7 +198,7 @@ func (cli *Client) Sync() error {
 	filterID := cli.Store.LoadFilterID(cli.UserID)
 	if filterID == "" {
 		filterJSON := cli.Syncer.GetFilterJSON(cli.UserID)
-		resFilter, err := cli.CreateFilter(filterJSON)
+		resFilter, err := cli.CreateFilterContext(ctx, filterJSON)
 		if err != nil {
 			return err
 		}
@@ -133,13 +207,21 @@ func (cli *Client) Sync() error {
 	}
 
 	for {
-		resSync, err := cli.SyncRequest(30000, nextBatch, filterID, false, "")
+		resSync, err := cli.SyncRequestContext(ctx, 30000, nextBatch, filterID, false, "")
 		if err != nil {
+			// A cancelled context is not a failed sync: don't ask the Syncer whether to retry.
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			duration, err2 := cli.Syncer.OnFailedSync(resSync, err)
 			if err2 != nil {
 				return err2
 			}
-			time.Sleep(duration)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(duration):
+			}
 			continue
 		}
 
@@ -187,74 +269,204 @@ func (cli *Client) StopSync() {
 // Returns the HTTP body as bytes on 2xx with a nil error. Returns an error if the response is not 2xx along
 // with the HTTP body bytes if it got that far. This error is an HTTPError which includes the returned
 // HTTP status code and possibly a response.Error as the WrappedError, if the HTTP body could be decoded as a response.Error.
+//
+// If the Client has a RetryPolicy, idempotent requests which are rate-limited by the homeserver are retried
+// according to it before an error is returned.
 func (cli *Client) MakeRequest(method string, httpURL string, reqBody interface{}, resBody interface{}) ([]byte, error) {
-	var req *http.Request
-	var err error
+	return cli.MakeRequestContext(context.Background(), method, httpURL, reqBody, resBody)
+}
+
+// MakeRequestContext is like MakeRequest, but the request is bound to ctx.
+func (cli *Client) MakeRequestContext(ctx context.Context, method string, httpURL string, reqBody interface{}, resBody interface{}) ([]byte, error) {
+	var jsonStr []byte
 	if reqBody != nil {
-		var jsonStr []byte
+		var err error
 		jsonStr, err = json.Marshal(reqBody)
 		if err != nil {
 			return nil, err
 		}
-		req, err = http.NewRequest(method, httpURL, bytes.NewBuffer(jsonStr))
-	} else {
-		req, err = http.NewRequest(method, httpURL, nil)
 	}
 
+	res, contents, err := cli.doWithRetries(ctx, method, httpURL, jsonStr)
 	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	res, err := cli.Client.Do(req)
-	if res != nil {
-		defer res.Body.Close()
+		return contents, err
 	}
+	defer res.Body.Close()
+	contents, err = ioutil.ReadAll(res.Body)
 	if err != nil {
 		return nil, err
 	}
-	contents, err := ioutil.ReadAll(res.Body)
-	if res.StatusCode/100 != 2 { // not 2xx
-		var wrap error
-		var respErr response.Error
-		if _ = json.Unmarshal(contents, &respErr); respErr.ErrCode != "" {
-			wrap = respErr
+
+	if resBody != nil {
+		if err = json.Unmarshal(contents, &resBody); err != nil {
+			return nil, err
+		}
+	}
+
+	return contents, nil
+}
+
+// doWithRetries performs an HTTP request like sendRequest, refreshing the access token if it has expired and
+// retrying rate-limited requests according to the RetryPolicy. If jsonStr is nil, no request body is sent.
+func (cli *Client) doWithRetries(ctx context.Context, method, httpURL string, jsonStr []byte) (*http.Response, []byte, error) {
+	attempt := 1
+	refreshed := false
+	for {
+		res, contents, err := cli.sendRequest(ctx, method, httpURL, jsonStr)
+		if err == nil {
+			return res, nil, nil
+		}
+		if !refreshed && cli.hasRefreshToken() && IsSoftLogout(err) {
+			// The access token has expired: refresh it and try again with the new one. If the refresh fails,
+			// the original error is returned, so that callers can still tell it was a soft logout.
+			refreshed = true
+			newURL, refreshErr := cli.refreshURL(ctx, httpURL)
+			if refreshErr != nil {
+				return nil, contents, err
+			}
+			httpURL = newURL
+			continue
+		}
+		var header http.Header
+		if res != nil {
+			header = res.Header
+		}
+		wait, retry := cli.RetryPolicy.shouldRetry(method, attempt, header, err)
+		if !retry {
+			return nil, contents, err
 		}
+		if cli.RetryPolicy.OnRetry != nil {
+			cli.RetryPolicy.OnRetry(attempt, wait, err.(HTTPError))
+		}
+		select {
+		case <-ctx.Done():
+			return nil, contents, ctx.Err()
+		case <-time.After(wait):
+		}
+		attempt++
+	}
+}
 
-		// If we failed to decode as response.Error, don't just drop the HTTP body, include it in the
-		// HTTP error instead (e.g proxy errors which return HTML).
-		msg := "Failed to " + method + " JSON to " + req.URL.Path
-		if wrap == nil {
-			msg = msg + ": " + string(contents)
+// hasRefreshToken returns true if the Client can refresh its access token.
+func (cli *Client) hasRefreshToken() bool {
+	cli.tokenMutex.RLock()
+	defer cli.tokenMutex.RUnlock()
+	return cli.RefreshToken != ""
+}
+
+// refreshURL refreshes the access token if the given URL was built with the current one, and returns the URL with
+// the new access token. Requests which fail at the same time wait for the first refresh and reuse its token, as the
+// refresh token may only be used once.
+func (cli *Client) refreshURL(ctx context.Context, httpURL string) (string, error) {
+	u, err := url.Parse(httpURL)
+	if err != nil {
+		return "", err
+	}
+	oldToken := u.Query().Get("access_token")
+
+	cli.refreshMutex.Lock()
+	defer cli.refreshMutex.Unlock()
+	if oldToken == cli.accessToken() {
+		// Nobody else has refreshed the token since the request was made.
+		if _, err = cli.refreshTokens(ctx); err != nil {
+			return "", err
 		}
+	}
+	if oldToken != "" {
+		q := u.Query()
+		q.Set("access_token", cli.accessToken())
+		u.RawQuery = q.Encode()
+	}
+	return u.String(), nil
+}
 
-		return contents, HTTPError{
-			Code:         res.StatusCode,
-			Message:      msg,
-			WrappedError: wrap,
+// doRequest performs a single JSON HTTP request. If jsonStr is nil, no request body is sent. Returns the HTTP body
+// and the response headers, along with an HTTPError if the response is not 2xx.
+func (cli *Client) doRequest(ctx context.Context, method, httpURL string, jsonStr []byte) ([]byte, http.Header, error) {
+	res, contents, err := cli.sendRequest(ctx, method, httpURL, jsonStr)
+	if err != nil {
+		var header http.Header
+		if res != nil {
+			header = res.Header
 		}
+		return contents, header, err
 	}
+	defer res.Body.Close()
+	contents, err = ioutil.ReadAll(res.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	return contents, res.Header, nil
+}
 
-	if resBody != nil {
-		if err = json.Unmarshal(contents, &resBody); err != nil {
-			return nil, err
+// sendRequest performs a single HTTP request. If jsonStr is nil, no request body is sent. If the response is 2xx, it
+// is returned with its body unread, and the caller must close it. Otherwise the body is read and returned, along with
+// the response (for its headers) and an HTTPError.
+func (cli *Client) sendRequest(ctx context.Context, method, httpURL string, jsonStr []byte) (*http.Response, []byte, error) {
+	var req *http.Request
+	var err error
+	if jsonStr != nil {
+		req, err = http.NewRequestWithContext(ctx, method, httpURL, bytes.NewBuffer(jsonStr))
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, httpURL, nil)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := cli.Client.Do(req)
+	if err != nil {
+		if res != nil {
+			res.Body.Close()
 		}
+		return nil, nil, err
+	}
+	if res.StatusCode/100 == 2 {
+		return res, nil, nil
 	}
 
-	return contents, nil
+	defer res.Body.Close()
+	contents, _ := ioutil.ReadAll(res.Body)
+	var wrap error
+	var respErr response.Error
+	if _ = json.Unmarshal(contents, &respErr); respErr.ErrCode != "" {
+		wrap = respErr
+	}
+
+	// If we failed to decode as response.Error, don't just drop the HTTP body, include it in the
+	// HTTP error instead (e.g proxy errors which return HTML).
+	msg := "Failed to " + method + " JSON to " + req.URL.Path
+	if wrap == nil {
+		msg = msg + ": " + string(contents)
+	}
+
+	return res, contents, HTTPError{
+		Code:         res.StatusCode,
+		Message:      msg,
+		WrappedError: wrap,
+	}
 }
 
 // CreateFilter makes an HTTP request according to http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-user-userid-filter
 func (cli *Client) CreateFilter(filter json.RawMessage) (resp *response.CreateFilter, err error) {
+	return cli.CreateFilterContext(context.Background(), filter)
+}
+
+// CreateFilterContext is like CreateFilter, but the request is bound to ctx.
+func (cli *Client) CreateFilterContext(ctx context.Context, filter json.RawMessage) (resp *response.CreateFilter, err error) {
 	urlPath := cli.BuildURL("user", cli.UserID, "filter")
-	_, err = cli.MakeRequest("POST", urlPath, &filter, &resp)
+	_, err = cli.MakeRequestContext(ctx, "POST", urlPath, &filter, &resp)
 	return
 }
 
 // SyncRequest makes an HTTP request according to http://matrix.org/docs/spec/client_server/r0.2.0.html#get-matrix-client-r0-sync
 func (cli *Client) SyncRequest(timeout int, since, filterID string, fullState bool, setPresence string) (resp *response.Sync, err error) {
+	return cli.SyncRequestContext(context.Background(), timeout, since, filterID, fullState, setPresence)
+}
+
+// SyncRequestContext is like SyncRequest, but the request is bound to ctx.
+func (cli *Client) SyncRequestContext(ctx context.Context, timeout int, since, filterID string, fullState bool, setPresence string) (resp *response.Sync, err error) {
 	query := map[string]string{
 		"timeout": strconv.Itoa(timeout),
 	}
@@ -271,13 +483,13 @@ func (cli *Client) SyncRequest(timeout int, since, filterID string, fullState bo
 		query["full_state"] = "true"
 	}
 	urlPath := cli.BuildURLWithQuery([]string{"sync"}, query)
-	_, err = cli.MakeRequest("GET", urlPath, nil, &resp)
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
 	return
 }
 
-func (cli *Client) register(u string, req *request.Register) (resp *response.Register, uiaResp *response.UserInteractive, err error) {
+func (cli *Client) register(ctx context.Context, u string, req *request.Register) (resp *response.Register, uiaResp *response.UserInteractive, err error) {
 	var bodyBytes []byte
-	bodyBytes, err = cli.MakeRequest("POST", u, req, nil)
+	bodyBytes, err = cli.MakeRequestContext(ctx, "POST", u, req, nil)
 	if err != nil {
 		httpErr, ok := err.(HTTPError)
 		if !ok { // network error
@@ -299,8 +511,13 @@ func (cli *Client) register(u string, req *request.Register) (resp *response.Reg
 //
 // Registers with kind=user. For kind=guest, see RegisterGuest.
 func (cli *Client) Register(req *request.Register) (*response.Register, *response.UserInteractive, error) {
+	return cli.RegisterContext(context.Background(), req)
+}
+
+// RegisterContext is like Register, but the request is bound to ctx.
+func (cli *Client) RegisterContext(ctx context.Context, req *request.Register) (*response.Register, *response.UserInteractive, error) {
 	u := cli.BuildURL("register")
-	return cli.register(u, req)
+	return cli.register(ctx, u, req)
 }
 
 // RegisterGuest makes an HTTP request according to http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-register
@@ -308,11 +525,16 @@ func (cli *Client) Register(req *request.Register) (*response.Register, *respons
 //
 // For kind=user, see Register.
 func (cli *Client) RegisterGuest(req *request.Register) (*response.Register, *response.UserInteractive, error) {
+	return cli.RegisterGuestContext(context.Background(), req)
+}
+
+// RegisterGuestContext is like RegisterGuest, but the request is bound to ctx.
+func (cli *Client) RegisterGuestContext(ctx context.Context, req *request.Register) (*response.Register, *response.UserInteractive, error) {
 	query := map[string]string{
 		"kind": "guest",
 	}
 	u := cli.BuildURLWithQuery([]string{"register"}, query)
-	return cli.register(u, req)
+	return cli.register(ctx, u, req)
 }
 
 // RegisterDummy performs m.login.dummy registration according to https://matrix.org/docs/spec/client_server/r0.2.0.html#dummy-auth
@@ -322,55 +544,396 @@ func (cli *Client) RegisterGuest(req *request.Register) (*response.Register, *re
 //
 // This does not set credentials on the client instance. See SetCredentials() instead.
 //
-// 	res, err := cli.RegisterDummy(&gomatrix.request.Register{
+//	res, err := cli.RegisterDummy(&gomatrix.request.Register{
 //		Username: "alice",
 //		Password: "wonderland",
 //	})
-//  if err != nil {
-// 		panic(err)
-// 	}
-// 	token := res.AccessToken
+//	if err != nil {
+//		panic(err)
+//	}
+//	token := res.AccessToken
 func (cli *Client) RegisterDummy(req *request.Register) (*response.Register, error) {
-	res, uia, err := cli.Register(req)
-	if err != nil && uia == nil {
-		return nil, err
-	}
-	if uia != nil && uia.HasSingleStageFlow("m.login.dummy") {
-		req.Auth = struct {
-			Type    string `json:"type"`
-			Session string `json:"session,omitempty"`
-		}{"m.login.dummy", uia.Session}
-		res, _, err = cli.Register(req)
-		if err != nil {
-			return nil, err
-		}
-	}
-	if res == nil {
-		return nil, fmt.Errorf("registration failed: does this server support m.login.dummy?")
+	return cli.RegisterDummyContext(context.Background(), req)
+}
+
+// RegisterDummyContext is like RegisterDummy, but the request is bound to ctx.
+func (cli *Client) RegisterDummyContext(ctx context.Context, req *request.Register) (*response.Register, error) {
+	uia := NewUIA()
+	uia.Handle(request.AuthTypeDummy, DummyStage())
+	res, err := cli.RegisterWithUIAContext(ctx, req, uia)
+	if err != nil {
+		return nil, fmt.Errorf("registration failed: does this server support m.login.dummy? %w", err)
 	}
 	return res, nil
 }
 
+// RegisterWithUIA makes an HTTP request according to http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-register
+// with kind=user, completing the user-interactive authentication stages the homeserver asks for with uia.
+//
+// This does not set credentials on the client instance. See SetCredentials() instead.
+func (cli *Client) RegisterWithUIA(req *request.Register, uia *UIA) (*response.Register, error) {
+	return cli.RegisterWithUIAContext(context.Background(), req, uia)
+}
+
+// RegisterWithUIAContext is like RegisterWithUIA, but the requests are bound to ctx.
+func (cli *Client) RegisterWithUIAContext(ctx context.Context, req *request.Register, uia *UIA) (resp *response.Register, err error) {
+	u := cli.BuildURL("register")
+	// work on a copy, so that the caller's request is left as it was
+	reqCopy := *req
+	_, err = cli.MakeUIARequestContext(ctx, "POST", u, uia, func(auth *request.AuthData) interface{} {
+		if auth != nil {
+			reqCopy.Auth = auth
+		}
+		return &reqCopy
+	}, &resp)
+	return
+}
+
 // Login a user to the homeserver according to http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-login
 // This does not set credentials on this client instance. See SetCredentials() instead.
 func (cli *Client) Login(req *request.Login) (resp *response.Login, err error) {
+	return cli.LoginContext(context.Background(), req)
+}
+
+// LoginContext is like Login, but the request is bound to ctx.
+func (cli *Client) LoginContext(ctx context.Context, req *request.Login) (resp *response.Login, err error) {
+	urlPath := cli.BuildURL("login")
+	_, err = cli.MakeRequestContext(ctx, "POST", urlPath, req, &resp)
+	return
+}
+
+// RefreshTokens exchanges the Client's RefreshToken for a new access token and refresh token, and sets them on the
+// client instance. OnTokensRefreshed is called with the new tokens.
+// See https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3refresh
+//
+// Requests which fail because the access token has expired call this automatically.
+func (cli *Client) RefreshTokens() (*response.Refresh, error) {
+	return cli.RefreshTokensContext(context.Background())
+}
+
+// RefreshTokensContext is like RefreshTokens, but the request is bound to ctx.
+func (cli *Client) RefreshTokensContext(ctx context.Context) (*response.Refresh, error) {
+	cli.refreshMutex.Lock()
+	defer cli.refreshMutex.Unlock()
+	return cli.refreshTokens(ctx)
+}
+
+// refreshTokens must be called with refreshMutex held. It bypasses MakeRequest, so that a rejected refresh token
+// doesn't trigger another refresh.
+func (cli *Client) refreshTokens(ctx context.Context) (resp *response.Refresh, err error) {
+	// /refresh does not take an access token: the old one has usually expired.
+	hsURL, _ := url.Parse(cli.HomeserverURL.String())
+	hsURL.Path = path.Join(hsURL.Path, "_matrix", "client", "v3", "refresh")
+	cli.tokenMutex.RLock()
+	jsonStr, err := json.Marshal(request.Refresh{RefreshToken: cli.RefreshToken})
+	cli.tokenMutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	contents, _, err := cli.doRequest(ctx, "POST", hsURL.String(), jsonStr)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(contents, &resp); err != nil {
+		return nil, err
+	}
+	cli.tokenMutex.Lock()
+	cli.AccessToken = resp.AccessToken
+	if resp.RefreshToken != "" {
+		cli.RefreshToken = resp.RefreshToken
+	}
+	cli.tokenMutex.Unlock()
+	if cli.OnTokensRefreshed != nil {
+		cli.OnTokensRefreshed(resp)
+	}
+	return resp, nil
+}
+
+// LoginFlows returns the login types supported by the homeserver. See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3login
+func (cli *Client) LoginFlows() (*response.LoginFlows, error) {
+	return cli.LoginFlowsContext(context.Background())
+}
+
+// LoginFlowsContext is like LoginFlows, but the request is bound to ctx.
+func (cli *Client) LoginFlowsContext(ctx context.Context) (resp *response.LoginFlows, err error) {
 	urlPath := cli.BuildURL("login")
-	_, err = cli.MakeRequest("POST", urlPath, req, &resp)
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
 	return
 }
 
+// LoginWithPassword logs in with m.login.password as the user identified by identifier.
+// See https://spec.matrix.org/latest/client-server-api/#password-based
+//
+// This does not set credentials on this client instance. See SetCredentials() instead.
+//
+//	resp, err := cli.LoginWithPassword(request.NewUserIdentifier("alice"), "wonderland")
+func (cli *Client) LoginWithPassword(identifier *request.UserIdentifier, password string) (*response.Login, error) {
+	return cli.LoginWithPasswordContext(context.Background(), identifier, password)
+}
+
+// LoginWithPasswordContext is like LoginWithPassword, but the request is bound to ctx.
+func (cli *Client) LoginWithPasswordContext(ctx context.Context, identifier *request.UserIdentifier, password string) (*response.Login, error) {
+	return cli.LoginContext(ctx, &request.Login{
+		Type:       request.AuthTypePassword,
+		Identifier: identifier,
+		Password:   password,
+	})
+}
+
+// LoginWithToken logs in with m.login.token, using a login token such as the one handed out at the end of SSO.
+// See https://spec.matrix.org/latest/client-server-api/#token-based
+//
+// This does not set credentials on this client instance. See SetCredentials() instead.
+func (cli *Client) LoginWithToken(token string) (*response.Login, error) {
+	return cli.LoginWithTokenContext(context.Background(), token)
+}
+
+// LoginWithTokenContext is like LoginWithToken, but the request is bound to ctx.
+func (cli *Client) LoginWithTokenContext(ctx context.Context, token string) (*response.Login, error) {
+	return cli.LoginContext(ctx, &request.Login{
+		Type:  request.AuthTypeToken,
+		Token: token,
+	})
+}
+
+// SSORedirectURL returns the URL which a browser should be sent to in order to log in with SSO. Once the user has
+// logged in, the browser is redirected to redirectURL with a loginToken query parameter, which can be passed to
+// LoginWithToken. If idpID is not empty, the user is sent straight to that identity provider (see LoginFlows).
+// See https://spec.matrix.org/latest/client-server-api/#client-login-via-sso
+//
+// The access token is never added to the URL.
+func (cli *Client) SSORedirectURL(idpID, redirectURL string) string {
+	hsURL, _ := url.Parse(cli.HomeserverURL.String())
+	parts := []string{hsURL.Path, cli.Prefix, "login", "sso", "redirect"}
+	if idpID != "" {
+		parts = append(parts, idpID)
+	}
+	hsURL.Path = path.Join(parts...)
+	hsURL.RawQuery = url.Values{"redirectUrl": {redirectURL}}.Encode()
+	return hsURL.String()
+}
+
+// LoginSSO logs in with SSO, using a local HTTP server on 127.0.0.1 to receive the login token.
+//
+// openURL is called with the SSO redirect URL, and should show it to the user or open it in their browser. LoginSSO
+// then blocks until the browser comes back to the local server, and logs in with the login token it was given.
+//
+// This does not set credentials on this client instance. See SetCredentials() instead.
+func (cli *Client) LoginSSO(idpID string, openURL func(ssoURL string) error) (*response.Login, error) {
+	return cli.LoginSSOContext(context.Background(), idpID, openURL)
+}
+
+// LoginSSOContext is like LoginSSO, but stops waiting for the browser when ctx is done.
+func (cli *Client) LoginSSOContext(ctx context.Context, idpID string, openURL func(ssoURL string) error) (*response.Login, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	tokens := make(chan string, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("loginToken")
+		if token == "" {
+			http.Error(w, "missing loginToken", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, "Login complete, you can close this window.")
+		select {
+		case tokens <- token:
+		default: // already logged in with an earlier token
+		}
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	if err = openURL(cli.SSORedirectURL(idpID, "http://"+ln.Addr().String()+"/")); err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case token := <-tokens:
+		return cli.LoginWithTokenContext(ctx, token)
+	}
+}
+
 // Logout the current user. See http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-logout
 // This does not clear the credentials from the client instance. See ClearCredentials() instead.
 func (cli *Client) Logout() (resp *response.Logout, err error) {
+	return cli.LogoutContext(context.Background())
+}
+
+// LogoutContext is like Logout, but the request is bound to ctx.
+func (cli *Client) LogoutContext(ctx context.Context) (resp *response.Logout, err error) {
 	urlPath := cli.BuildURL("logout")
-	_, err = cli.MakeRequest("POST", urlPath, nil, &resp)
+	_, err = cli.MakeRequestContext(ctx, "POST", urlPath, nil, &resp)
+	return
+}
+
+// LogoutAll logs out every device of the current user, including this one. See https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3logoutall
+// This does not clear the credentials from the client instance. See ClearCredentials() instead.
+func (cli *Client) LogoutAll() (*response.Logout, error) {
+	return cli.LogoutAllContext(context.Background())
+}
+
+// LogoutAllContext is like LogoutAll, but the request is bound to ctx.
+func (cli *Client) LogoutAllContext(ctx context.Context) (resp *response.Logout, err error) {
+	urlPath := cli.BuildURL("logout", "all")
+	_, err = cli.MakeRequestContext(ctx, "POST", urlPath, nil, &resp)
+	return
+}
+
+// WhoAmI returns the user ID and device ID which the access token belongs to. See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3accountwhoami
+func (cli *Client) WhoAmI() (*response.WhoAmI, error) {
+	return cli.WhoAmIContext(context.Background())
+}
+
+// WhoAmIContext is like WhoAmI, but the request is bound to ctx.
+func (cli *Client) WhoAmIContext(ctx context.Context) (resp *response.WhoAmI, err error) {
+	urlPath := cli.BuildURL("account", "whoami")
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
+	return
+}
+
+// ChangePassword changes the password of the current user. If logoutDevices is true, every other device of the
+// user is logged out. The homeserver requires user-interactive authentication, which is completed with uia.
+// See https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3accountpassword
+func (cli *Client) ChangePassword(newPassword string, logoutDevices bool, uia *UIA) error {
+	return cli.ChangePasswordContext(context.Background(), newPassword, logoutDevices, uia)
+}
+
+// ChangePasswordContext is like ChangePassword, but the requests are bound to ctx.
+func (cli *Client) ChangePasswordContext(ctx context.Context, newPassword string, logoutDevices bool, uia *UIA) (err error) {
+	urlPath := cli.BuildURL("account", "password")
+	_, err = cli.MakeUIARequestContext(ctx, "POST", urlPath, uia, func(auth *request.AuthData) interface{} {
+		return &request.ChangePassword{NewPassword: newPassword, LogoutDevices: &logoutDevices, Auth: auth}
+	}, nil)
+	return
+}
+
+// DeactivateAccount deactivates the current user's account, so that it can never be logged into again. If erase is
+// true, the homeserver is also asked to forget the messages the user has sent. The homeserver requires
+// user-interactive authentication, which is completed with uia.
+// See https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3accountdeactivate
+func (cli *Client) DeactivateAccount(erase bool, uia *UIA) (*response.DeactivateAccount, error) {
+	return cli.DeactivateAccountContext(context.Background(), erase, uia)
+}
+
+// DeactivateAccountContext is like DeactivateAccount, but the requests are bound to ctx.
+func (cli *Client) DeactivateAccountContext(ctx context.Context, erase bool, uia *UIA) (resp *response.DeactivateAccount, err error) {
+	urlPath := cli.BuildURL("account", "deactivate")
+	_, err = cli.MakeUIARequestContext(ctx, "POST", urlPath, uia, func(auth *request.AuthData) interface{} {
+		return &request.DeactivateAccount{Erase: erase, Auth: auth}
+	}, &resp)
+	return
+}
+
+// ThreePIDs returns the third-party identifiers associated with the current user. See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3account3pid
+func (cli *Client) ThreePIDs() (*response.ThreePIDs, error) {
+	return cli.ThreePIDsContext(context.Background())
+}
+
+// ThreePIDsContext is like ThreePIDs, but the request is bound to ctx.
+func (cli *Client) ThreePIDsContext(ctx context.Context) (resp *response.ThreePIDs, err error) {
+	urlPath := cli.BuildURL("account", "3pid")
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
+	return
+}
+
+// RequestEmailToken asks the homeserver to send an email with a validation token to an address which the user wants
+// to add to their account. The returned session ID and the client secret are then passed to AddThreePID.
+// See https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3account3pidemailrequesttoken
+func (cli *Client) RequestEmailToken(req *request.EmailRequestToken) (*response.RequestToken, error) {
+	return cli.RequestEmailTokenContext(context.Background(), req)
+}
+
+// RequestEmailTokenContext is like RequestEmailToken, but the request is bound to ctx.
+func (cli *Client) RequestEmailTokenContext(ctx context.Context, req *request.EmailRequestToken) (resp *response.RequestToken, err error) {
+	urlPath := cli.BuildURL("account", "3pid", "email", "requestToken")
+	_, err = cli.MakeRequestContext(ctx, "POST", urlPath, req, &resp)
+	return
+}
+
+// RequestMsisdnToken asks the homeserver to send an SMS with a validation token to a phone number which the user wants
+// to add to their account. The returned session ID and the client secret are then passed to AddThreePID.
+// See https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3account3pidmsisdnrequesttoken
+func (cli *Client) RequestMsisdnToken(req *request.MsisdnRequestToken) (*response.RequestToken, error) {
+	return cli.RequestMsisdnTokenContext(context.Background(), req)
+}
+
+// RequestMsisdnTokenContext is like RequestMsisdnToken, but the request is bound to ctx.
+func (cli *Client) RequestMsisdnTokenContext(ctx context.Context, req *request.MsisdnRequestToken) (resp *response.RequestToken, err error) {
+	urlPath := cli.BuildURL("account", "3pid", "msisdn", "requestToken")
+	_, err = cli.MakeRequestContext(ctx, "POST", urlPath, req, &resp)
+	return
+}
+
+// AddThreePID adds a validated third-party identifier to the current user's account. The homeserver requires
+// user-interactive authentication, which is completed with uia.
+// See https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3account3pidadd
+func (cli *Client) AddThreePID(clientSecret, sid string, uia *UIA) error {
+	return cli.AddThreePIDContext(context.Background(), clientSecret, sid, uia)
+}
+
+// AddThreePIDContext is like AddThreePID, but the requests are bound to ctx.
+func (cli *Client) AddThreePIDContext(ctx context.Context, clientSecret, sid string, uia *UIA) (err error) {
+	urlPath := cli.BuildURL("account", "3pid", "add")
+	_, err = cli.MakeUIARequestContext(ctx, "POST", urlPath, uia, func(auth *request.AuthData) interface{} {
+		return &request.AddThreePID{ClientSecret: clientSecret, SID: sid, Auth: auth}
+	}, nil)
+	return
+}
+
+// BindThreePID binds a third-party identifier of the current user to an identity server, so that other users can
+// find them by it. See https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3account3pidbind
+func (cli *Client) BindThreePID(req *request.BindThreePID) error {
+	return cli.BindThreePIDContext(context.Background(), req)
+}
+
+// BindThreePIDContext is like BindThreePID, but the request is bound to ctx.
+func (cli *Client) BindThreePIDContext(ctx context.Context, req *request.BindThreePID) (err error) {
+	urlPath := cli.BuildURL("account", "3pid", "bind")
+	_, err = cli.MakeRequestContext(ctx, "POST", urlPath, req, nil)
+	return
+}
+
+// UnbindThreePID unbinds a third-party identifier of the current user from an identity server, but keeps it on the
+// account. If idServer is empty, the homeserver unbinds it from the identity server it was bound with.
+// See https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3account3pidunbind
+func (cli *Client) UnbindThreePID(medium, address, idServer string) (*response.UnbindThreePID, error) {
+	return cli.UnbindThreePIDContext(context.Background(), medium, address, idServer)
+}
+
+// UnbindThreePIDContext is like UnbindThreePID, but the request is bound to ctx.
+func (cli *Client) UnbindThreePIDContext(ctx context.Context, medium, address, idServer string) (resp *response.UnbindThreePID, err error) {
+	urlPath := cli.BuildURL("account", "3pid", "unbind")
+	req := &request.ThreePID{Medium: medium, Address: address, IDServer: idServer}
+	_, err = cli.MakeRequestContext(ctx, "POST", urlPath, req, &resp)
+	return
+}
+
+// DeleteThreePID removes a third-party identifier from the current user's account, unbinding it from the identity
+// server too. See https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3account3piddelete
+func (cli *Client) DeleteThreePID(medium, address, idServer string) (*response.UnbindThreePID, error) {
+	return cli.DeleteThreePIDContext(context.Background(), medium, address, idServer)
+}
+
+// DeleteThreePIDContext is like DeleteThreePID, but the request is bound to ctx.
+func (cli *Client) DeleteThreePIDContext(ctx context.Context, medium, address, idServer string) (resp *response.UnbindThreePID, err error) {
+	urlPath := cli.BuildURL("account", "3pid", "delete")
+	req := &request.ThreePID{Medium: medium, Address: address, IDServer: idServer}
+	_, err = cli.MakeRequestContext(ctx, "POST", urlPath, req, &resp)
 	return
 }
 
 // Versions returns the list of supported Matrix versions on this homeserver. See http://matrix.org/docs/spec/client_server/r0.2.0.html#get-matrix-client-versions
 func (cli *Client) Versions() (resp *response.Versions, err error) {
+	return cli.VersionsContext(context.Background())
+}
+
+// VersionsContext is like Versions, but the request is bound to ctx.
+func (cli *Client) VersionsContext(ctx context.Context) (resp *response.Versions, err error) {
 	urlPath := cli.BuildBaseURL("_matrix", "client", "versions")
-	_, err = cli.MakeRequest("GET", urlPath, nil, &resp)
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
 	return
 }
 
@@ -379,6 +942,11 @@ func (cli *Client) Versions() (resp *response.Versions, err error) {
 // If serverName is specified, this will be added as a query param to instruct the homeserver to join via that server. If content is specified, it will
 // be JSON encoded and used as the request body.
 func (cli *Client) JoinRoom(roomIDorAlias, serverName string, content interface{}) (resp *response.JoinRoom, err error) {
+	return cli.JoinRoomContext(context.Background(), roomIDorAlias, serverName, content)
+}
+
+// JoinRoomContext is like JoinRoom, but the request is bound to ctx.
+func (cli *Client) JoinRoomContext(ctx context.Context, roomIDorAlias, serverName string, content interface{}) (resp *response.JoinRoom, err error) {
 	var urlPath string
 	if serverName != "" {
 		urlPath = cli.BuildURLWithQuery([]string{"join", roomIDorAlias}, map[string]string{
@@ -387,42 +955,72 @@ func (cli *Client) JoinRoom(roomIDorAlias, serverName string, content interface{
 	} else {
 		urlPath = cli.BuildURL("join", roomIDorAlias)
 	}
-	_, err = cli.MakeRequest("POST", urlPath, content, &resp)
+	_, err = cli.MakeRequestContext(ctx, "POST", urlPath, content, &resp)
 	return
 }
 
 // GetDisplayName returns the display name of the user from the specified MXID. See https://matrix.org/docs/spec/client_server/r0.2.0.html#get-matrix-client-r0-profile-userid-displayname
 func (cli *Client) GetDisplayName(mxid string) (resp *response.UserDisplayName, err error) {
+	return cli.GetDisplayNameContext(context.Background(), mxid)
+}
+
+// GetDisplayNameContext is like GetDisplayName, but the request is bound to ctx.
+func (cli *Client) GetDisplayNameContext(ctx context.Context, mxid string) (resp *response.UserDisplayName, err error) {
 	urlPath := cli.BuildURL("profile", mxid, "displayname")
-	_, err = cli.MakeRequest("GET", urlPath, nil, &resp)
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
 	return
 }
 
 // GetOwnDisplayName returns the user's display name. See https://matrix.org/docs/spec/client_server/r0.2.0.html#get-matrix-client-r0-profile-userid-displayname
 func (cli *Client) GetOwnDisplayName() (resp *response.UserDisplayName, err error) {
+	return cli.GetOwnDisplayNameContext(context.Background())
+}
+
+// GetOwnDisplayNameContext is like GetOwnDisplayName, but the request is bound to ctx.
+func (cli *Client) GetOwnDisplayNameContext(ctx context.Context) (resp *response.UserDisplayName, err error) {
 	urlPath := cli.BuildURL("profile", cli.UserID, "displayname")
-	_, err = cli.MakeRequest("GET", urlPath, nil, &resp)
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
 	return
 }
 
 // SetDisplayName sets the user's profile display name. See http://matrix.org/docs/spec/client_server/r0.2.0.html#put-matrix-client-r0-profile-userid-displayname
 func (cli *Client) SetDisplayName(displayName string) (err error) {
+	return cli.SetDisplayNameContext(context.Background(), displayName)
+}
+
+// SetDisplayNameContext is like SetDisplayName, but the request is bound to ctx.
+func (cli *Client) SetDisplayNameContext(ctx context.Context, displayName string) (err error) {
 	urlPath := cli.BuildURL("profile", cli.UserID, "displayname")
 	s := struct {
 		DisplayName string `json:"displayname"`
 	}{displayName}
-	_, err = cli.MakeRequest("PUT", urlPath, &s, nil)
+	_, err = cli.MakeRequestContext(ctx, "PUT", urlPath, &s, nil)
 	return
 }
 
 // GetAvatarURL gets the user's avatar URL. See http://matrix.org/docs/spec/client_server/r0.2.0.html#get-matrix-client-r0-profile-userid-avatar-url
 func (cli *Client) GetAvatarURL() (url string, err error) {
-	urlPath := cli.BuildURL("profile", cli.UserID, "avatar_url")
+	return cli.GetAvatarURLContext(context.Background())
+}
+
+// GetAvatarURLContext is like GetAvatarURL, but the request is bound to ctx.
+func (cli *Client) GetAvatarURLContext(ctx context.Context) (url string, err error) {
+	return cli.GetUserAvatarURLContext(ctx, cli.UserID)
+}
+
+// GetUserAvatarURL gets the avatar URL of the user from the specified MXID. See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3profileuseridavatar_url
+func (cli *Client) GetUserAvatarURL(mxid string) (url string, err error) {
+	return cli.GetUserAvatarURLContext(context.Background(), mxid)
+}
+
+// GetUserAvatarURLContext is like GetUserAvatarURL, but the request is bound to ctx.
+func (cli *Client) GetUserAvatarURLContext(ctx context.Context, mxid string) (url string, err error) {
+	urlPath := cli.BuildURL("profile", mxid, "avatar_url")
 	s := struct {
 		AvatarURL string `json:"avatar_url"`
 	}{}
 
-	_, err = cli.MakeRequest("GET", urlPath, nil, &s)
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &s)
 	if err != nil {
 		return "", err
 	}
@@ -430,13 +1028,31 @@ func (cli *Client) GetAvatarURL() (url string, err error) {
 	return s.AvatarURL, nil
 }
 
+// GetProfile returns the display name and avatar URL of the user from the specified MXID in a single request.
+// See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3profileuserid
+func (cli *Client) GetProfile(mxid string) (*response.Profile, error) {
+	return cli.GetProfileContext(context.Background(), mxid)
+}
+
+// GetProfileContext is like GetProfile, but the request is bound to ctx.
+func (cli *Client) GetProfileContext(ctx context.Context, mxid string) (resp *response.Profile, err error) {
+	urlPath := cli.BuildURL("profile", mxid)
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
+	return
+}
+
 // SetAvatarURL sets the user's avatar URL. See http://matrix.org/docs/spec/client_server/r0.2.0.html#put-matrix-client-r0-profile-userid-avatar-url
 func (cli *Client) SetAvatarURL(url string) (err error) {
+	return cli.SetAvatarURLContext(context.Background(), url)
+}
+
+// SetAvatarURLContext is like SetAvatarURL, but the request is bound to ctx.
+func (cli *Client) SetAvatarURLContext(ctx context.Context, url string) (err error) {
 	urlPath := cli.BuildURL("profile", cli.UserID, "avatar_url")
 	s := struct {
 		AvatarURL string `json:"avatar_url"`
 	}{url}
-	_, err = cli.MakeRequest("PUT", urlPath, &s, nil)
+	_, err = cli.MakeRequestContext(ctx, "PUT", urlPath, &s, nil)
 	if err != nil {
 		return err
 	}
@@ -444,34 +1060,121 @@ func (cli *Client) SetAvatarURL(url string) (err error) {
 	return nil
 }
 
-// SendMessageEvent sends a message event into a room. See http://matrix.org/docs/spec/client_server/r0.2.0.html#put-matrix-client-r0-rooms-roomid-send-eventtype-txnid
-// contentJSON should be a pointer to something that can be encoded as JSON using json.Marshal.
+// SetRoomDisplayName sets the user's display name in a single room, without changing their global profile. This sends
+// an m.room.member state event built from the user's current one, so the user must have joined the room.
+// See https://spec.matrix.org/latest/client-server-api/#mroommember
+func (cli *Client) SetRoomDisplayName(roomID, displayName string) (*response.SendEvent, error) {
+	return cli.SetRoomDisplayNameContext(context.Background(), roomID, displayName)
+}
+
+// SetRoomDisplayNameContext is like SetRoomDisplayName, but the requests are bound to ctx.
+func (cli *Client) SetRoomDisplayNameContext(ctx context.Context, roomID, displayName string) (*response.SendEvent, error) {
+	return cli.updateOwnMember(ctx, roomID, "displayname", displayName)
+}
+
+// SetRoomAvatarURL sets the user's avatar URL in a single room, without changing their global profile. This sends
+// an m.room.member state event built from the user's current one, so the user must have joined the room.
+// See https://spec.matrix.org/latest/client-server-api/#mroommember
+func (cli *Client) SetRoomAvatarURL(roomID, url string) (*response.SendEvent, error) {
+	return cli.SetRoomAvatarURLContext(context.Background(), roomID, url)
+}
+
+// SetRoomAvatarURLContext is like SetRoomAvatarURL, but the requests are bound to ctx.
+func (cli *Client) SetRoomAvatarURLContext(ctx context.Context, roomID, url string) (*response.SendEvent, error) {
+	return cli.updateOwnMember(ctx, roomID, "avatar_url", url)
+}
+
+// updateOwnMember sets a single key of the user's m.room.member event in the given room. The event content is
+// handled as a map so that fields this library doesn't know about are kept as they are.
+func (cli *Client) updateOwnMember(ctx context.Context, roomID, key, value string) (*response.SendEvent, error) {
+	content := make(map[string]interface{})
+	if err := cli.StateEventContext(ctx, roomID, "m.room.member", cli.UserID, &content); err != nil {
+		return nil, err
+	}
+	if content["membership"] != "join" {
+		return nil, fmt.Errorf("%s has not joined %s", cli.UserID, roomID)
+	}
+	if value == "" {
+		delete(content, key)
+	} else {
+		content[key] = value
+	}
+	return cli.SendStateEventContext(ctx, roomID, "m.room.member", cli.UserID, content)
+}
+
+// GetPresence returns the presence state of the user from the specified MXID. See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3presenceuseridstatus
+func (cli *Client) GetPresence(mxid string) (*response.Presence, error) {
+	return cli.GetPresenceContext(context.Background(), mxid)
+}
+
+// GetPresenceContext is like GetPresence, but the request is bound to ctx.
+func (cli *Client) GetPresenceContext(ctx context.Context, mxid string) (resp *response.Presence, err error) {
+	urlPath := cli.BuildURL("presence", mxid, "status")
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
+	return
+}
+
+// SetPresence sets the user's presence state, e.g. event.PresenceOnline, and status message. An empty status message
+// clears it. See https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3presenceuseridstatus
+func (cli *Client) SetPresence(presence, statusMsg string) error {
+	return cli.SetPresenceContext(context.Background(), presence, statusMsg)
+}
+
+// SetPresenceContext is like SetPresence, but the request is bound to ctx.
+func (cli *Client) SetPresenceContext(ctx context.Context, presence, statusMsg string) (err error) {
+	urlPath := cli.BuildURL("presence", cli.UserID, "status")
+	_, err = cli.MakeRequestContext(ctx, "PUT", urlPath, &request.Presence{Presence: presence, StatusMsg: statusMsg}, nil)
+	return
+}
+
+// SendMessageEvent sends a message event into a room. See http://matrix.org/docs/spec/client_server/r0.2.0.html#put-matrix-client-r0-rooms-roomid-send-eventtype-txnid
+// contentJSON should be a pointer to something that can be encoded as JSON using json.Marshal.
 func (cli *Client) SendMessageEvent(roomID string, eventType string, contentJSON interface{}) (resp *response.SendEvent, err error) {
+	return cli.SendMessageEventContext(context.Background(), roomID, eventType, contentJSON)
+}
+
+// SendMessageEventContext is like SendMessageEvent, but the request is bound to ctx.
+func (cli *Client) SendMessageEventContext(ctx context.Context, roomID string, eventType string, contentJSON interface{}) (resp *response.SendEvent, err error) {
 	txnID := txnID()
 	urlPath := cli.BuildURL("rooms", roomID, "send", eventType, txnID)
-	_, err = cli.MakeRequest("PUT", urlPath, contentJSON, &resp)
+	_, err = cli.MakeRequestContext(ctx, "PUT", urlPath, contentJSON, &resp)
 	return
 }
 
 // SendStateEvent sends a state event into a room. See http://matrix.org/docs/spec/client_server/r0.2.0.html#put-matrix-client-r0-rooms-roomid-state-eventtype-statekey
 // contentJSON should be a pointer to something that can be encoded as JSON using json.Marshal.
 func (cli *Client) SendStateEvent(roomID, eventType, stateKey string, contentJSON interface{}) (resp *response.SendEvent, err error) {
+	return cli.SendStateEventContext(context.Background(), roomID, eventType, stateKey, contentJSON)
+}
+
+// SendStateEventContext is like SendStateEvent, but the request is bound to ctx.
+func (cli *Client) SendStateEventContext(ctx context.Context, roomID, eventType, stateKey string, contentJSON interface{}) (resp *response.SendEvent, err error) {
 	urlPath := cli.BuildURL("rooms", roomID, "state", eventType, stateKey)
-	_, err = cli.MakeRequest("PUT", urlPath, contentJSON, &resp)
+	_, err = cli.MakeRequestContext(ctx, "PUT", urlPath, contentJSON, &resp)
 	return
 }
 
 // SendText sends an m.room.message event into the given room with a msgtype of m.text
 // See http://matrix.org/docs/spec/client_server/r0.2.0.html#m-text
 func (cli *Client) SendText(roomID, text string) (*response.SendEvent, error) {
-	return cli.SendMessageEvent(roomID, "m.room.message",
+	return cli.SendTextContext(context.Background(), roomID, text)
+}
+
+// SendTextContext is like SendText, but the request is bound to ctx.
+func (cli *Client) SendTextContext(ctx context.Context, roomID, text string) (*response.SendEvent, error) {
+	return cli.SendMessageEventContext(ctx, roomID, "m.room.message",
 		event.TextMessage{Body: text})
 }
 
 // SendImage sends an m.room.message event into the given room with a msgtype of m.image
 // See https://matrix.org/docs/spec/client_server/r0.2.0.html#m-image
 func (cli *Client) SendImage(roomID, body, url string) (*response.SendEvent, error) {
-	return cli.SendMessageEvent(roomID, "m.room.message",
+	return cli.SendImageContext(context.Background(), roomID, body, url)
+}
+
+// SendImageContext is like SendImage, but the request is bound to ctx.
+func (cli *Client) SendImageContext(ctx context.Context, roomID, body, url string) (*response.SendEvent, error) {
+	return cli.SendMessageEventContext(ctx, roomID, "m.room.message",
 		event.ImageMessage{
 			Body: body,
 			URL:  url,
@@ -481,7 +1184,12 @@ func (cli *Client) SendImage(roomID, body, url string) (*response.SendEvent, err
 // SendVideo sends an m.room.message event into the given room with a msgtype of m.video
 // See https://matrix.org/docs/spec/client_server/r0.2.0.html#m-video
 func (cli *Client) SendVideo(roomID, body, url string) (*response.SendEvent, error) {
-	return cli.SendMessageEvent(roomID, "m.room.message",
+	return cli.SendVideoContext(context.Background(), roomID, body, url)
+}
+
+// SendVideoContext is like SendVideo, but the request is bound to ctx.
+func (cli *Client) SendVideoContext(ctx context.Context, roomID, body, url string) (*response.SendEvent, error) {
+	return cli.SendMessageEventContext(ctx, roomID, "m.room.message",
 		event.VideoMessage{
 			Body: body,
 			URL:  url,
@@ -491,82 +1199,165 @@ func (cli *Client) SendVideo(roomID, body, url string) (*response.SendEvent, err
 // SendNotice sends an m.room.message event into the given room with a msgtype of m.notice
 // See http://matrix.org/docs/spec/client_server/r0.2.0.html#m-notice
 func (cli *Client) SendNotice(roomID, text string) (*response.SendEvent, error) {
-	return cli.SendMessageEvent(roomID, "m.room.message", event.NoticeMessage{Body: text})
+	return cli.SendNoticeContext(context.Background(), roomID, text)
+}
+
+// SendNoticeContext is like SendNotice, but the request is bound to ctx.
+func (cli *Client) SendNoticeContext(ctx context.Context, roomID, text string) (*response.SendEvent, error) {
+	return cli.SendMessageEventContext(ctx, roomID, "m.room.message", event.NoticeMessage{Body: text})
 }
 
 // RedactEvent redacts the given event. See http://matrix.org/docs/spec/client_server/r0.2.0.html#put-matrix-client-r0-rooms-roomid-redact-eventid-txnid
 func (cli *Client) RedactEvent(roomID, eventID string, req *request.Redact) (resp *response.SendEvent, err error) {
+	return cli.RedactEventContext(context.Background(), roomID, eventID, req)
+}
+
+// RedactEventContext is like RedactEvent, but the request is bound to ctx.
+func (cli *Client) RedactEventContext(ctx context.Context, roomID, eventID string, req *request.Redact) (resp *response.SendEvent, err error) {
 	txnID := txnID()
 	urlPath := cli.BuildURL("rooms", roomID, "redact", eventID, txnID)
-	_, err = cli.MakeRequest("PUT", urlPath, req, &resp)
+	_, err = cli.MakeRequestContext(ctx, "PUT", urlPath, req, &resp)
 	return
 }
 
 // CreateRoom creates a new Matrix room. See https://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-createroom
-//  resp, err := cli.CreateRoom(&gomatrix.request.CreateRoom{
-//  	Preset: "public_chat",
-//  })
-//  fmt.Println("Room:", resp.RoomID)
+//
+//	resp, err := cli.CreateRoom(&gomatrix.request.CreateRoom{
+//		Preset: "public_chat",
+//	})
+//	fmt.Println("Room:", resp.RoomID)
 func (cli *Client) CreateRoom(req *request.CreateRoom) (resp *response.CreateRoom, err error) {
+	return cli.CreateRoomContext(context.Background(), req)
+}
+
+// CreateRoomContext is like CreateRoom, but the request is bound to ctx.
+func (cli *Client) CreateRoomContext(ctx context.Context, req *request.CreateRoom) (resp *response.CreateRoom, err error) {
 	urlPath := cli.BuildURL("createRoom")
-	_, err = cli.MakeRequest("POST", urlPath, req, &resp)
+	_, err = cli.MakeRequestContext(ctx, "POST", urlPath, req, &resp)
 	return
 }
 
 // LeaveRoom leaves the given room. See http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-rooms-roomid-leave
 func (cli *Client) LeaveRoom(roomID string) (resp *response.LeaveRoom, err error) {
+	return cli.LeaveRoomContext(context.Background(), roomID)
+}
+
+// LeaveRoomContext is like LeaveRoom, but the request is bound to ctx.
+func (cli *Client) LeaveRoomContext(ctx context.Context, roomID string) (resp *response.LeaveRoom, err error) {
 	u := cli.BuildURL("rooms", roomID, "leave")
-	_, err = cli.MakeRequest("POST", u, struct{}{}, &resp)
+	_, err = cli.MakeRequestContext(ctx, "POST", u, struct{}{}, &resp)
 	return
 }
 
 // ForgetRoom forgets a room entirely. See http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-rooms-roomid-forget
 func (cli *Client) ForgetRoom(roomID string) (resp *response.ForgetRoom, err error) {
+	return cli.ForgetRoomContext(context.Background(), roomID)
+}
+
+// ForgetRoomContext is like ForgetRoom, but the request is bound to ctx.
+func (cli *Client) ForgetRoomContext(ctx context.Context, roomID string) (resp *response.ForgetRoom, err error) {
 	u := cli.BuildURL("rooms", roomID, "forget")
-	_, err = cli.MakeRequest("POST", u, struct{}{}, &resp)
+	_, err = cli.MakeRequestContext(ctx, "POST", u, struct{}{}, &resp)
 	return
 }
 
 // InviteUser invites a user to a room. See http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-rooms-roomid-invite
 func (cli *Client) InviteUser(roomID string, req *request.InviteUser) (resp *response.InviteUser, err error) {
+	return cli.InviteUserContext(context.Background(), roomID, req)
+}
+
+// InviteUserContext is like InviteUser, but the request is bound to ctx.
+func (cli *Client) InviteUserContext(ctx context.Context, roomID string, req *request.InviteUser) (resp *response.InviteUser, err error) {
 	u := cli.BuildURL("rooms", roomID, "invite")
-	_, err = cli.MakeRequest("POST", u, req, &resp)
+	_, err = cli.MakeRequestContext(ctx, "POST", u, req, &resp)
 	return
 }
 
 // InviteUserByThirdParty invites a third-party identifier to a room. See http://matrix.org/docs/spec/client_server/r0.2.0.html#invite-by-third-party-id-endpoint
 func (cli *Client) InviteUserByThirdParty(roomID string, req *request.Invite3PID) (resp *response.InviteUser, err error) {
+	return cli.InviteUserByThirdPartyContext(context.Background(), roomID, req)
+}
+
+// InviteUserByThirdPartyContext is like InviteUserByThirdParty, but the request is bound to ctx.
+func (cli *Client) InviteUserByThirdPartyContext(ctx context.Context, roomID string, req *request.Invite3PID) (resp *response.InviteUser, err error) {
 	u := cli.BuildURL("rooms", roomID, "invite")
-	_, err = cli.MakeRequest("POST", u, req, &resp)
+	_, err = cli.MakeRequestContext(ctx, "POST", u, req, &resp)
 	return
 }
 
 // KickUser kicks a user from a room. See http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-rooms-roomid-kick
 func (cli *Client) KickUser(roomID string, req *request.KickUser) (resp *response.KickUser, err error) {
+	return cli.KickUserContext(context.Background(), roomID, req)
+}
+
+// KickUserContext is like KickUser, but the request is bound to ctx.
+func (cli *Client) KickUserContext(ctx context.Context, roomID string, req *request.KickUser) (resp *response.KickUser, err error) {
 	u := cli.BuildURL("rooms", roomID, "kick")
-	_, err = cli.MakeRequest("POST", u, req, &resp)
+	_, err = cli.MakeRequestContext(ctx, "POST", u, req, &resp)
 	return
 }
 
 // BanUser bans a user from a room. See http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-rooms-roomid-ban
 func (cli *Client) BanUser(roomID string, req *request.BanUser) (resp *response.BanUser, err error) {
+	return cli.BanUserContext(context.Background(), roomID, req)
+}
+
+// BanUserContext is like BanUser, but the request is bound to ctx.
+func (cli *Client) BanUserContext(ctx context.Context, roomID string, req *request.BanUser) (resp *response.BanUser, err error) {
 	u := cli.BuildURL("rooms", roomID, "ban")
-	_, err = cli.MakeRequest("POST", u, req, &resp)
+	_, err = cli.MakeRequestContext(ctx, "POST", u, req, &resp)
 	return
 }
 
 // UnbanUser unbans a user from a room. See http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-rooms-roomid-unban
 func (cli *Client) UnbanUser(roomID string, req *request.UnbanUser) (resp *response.UnbanUser, err error) {
+	return cli.UnbanUserContext(context.Background(), roomID, req)
+}
+
+// UnbanUserContext is like UnbanUser, but the request is bound to ctx.
+func (cli *Client) UnbanUserContext(ctx context.Context, roomID string, req *request.UnbanUser) (resp *response.UnbanUser, err error) {
 	u := cli.BuildURL("rooms", roomID, "unban")
-	_, err = cli.MakeRequest("POST", u, req, &resp)
+	_, err = cli.MakeRequestContext(ctx, "POST", u, req, &resp)
 	return
 }
 
 // UserTyping sets the typing status of the user. See https://matrix.org/docs/spec/client_server/r0.2.0.html#put-matrix-client-r0-rooms-roomid-typing-userid
 func (cli *Client) UserTyping(roomID string, typing bool, timeout int64) (resp *response.Typing, err error) {
+	return cli.UserTypingContext(context.Background(), roomID, typing, timeout)
+}
+
+// UserTypingContext is like UserTyping, but the request is bound to ctx.
+func (cli *Client) UserTypingContext(ctx context.Context, roomID string, typing bool, timeout int64) (resp *response.Typing, err error) {
 	req := request.Typing{Typing: typing, Timeout: timeout}
 	u := cli.BuildURL("rooms", roomID, "typing", cli.UserID)
-	_, err = cli.MakeRequest("PUT", u, req, &resp)
+	_, err = cli.MakeRequestContext(ctx, "PUT", u, req, &resp)
+	return
+}
+
+// SendReceipt sends a receipt of the given type, e.g. event.ReceiptTypeRead, for an event. threadID restricts the
+// receipt to a thread, or to events outside of any thread with event.ThreadIDMain. If it is empty, the receipt is
+// unthreaded. See https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3roomsroomidreceiptreceipttypeeventid
+func (cli *Client) SendReceipt(roomID, eventID, receiptType, threadID string) error {
+	return cli.SendReceiptContext(context.Background(), roomID, eventID, receiptType, threadID)
+}
+
+// SendReceiptContext is like SendReceipt, but the request is bound to ctx.
+func (cli *Client) SendReceiptContext(ctx context.Context, roomID, eventID, receiptType, threadID string) (err error) {
+	urlPath := cli.BuildURL("rooms", roomID, "receipt", receiptType, eventID)
+	_, err = cli.MakeRequestContext(ctx, "POST", urlPath, &request.Receipt{ThreadID: threadID}, nil)
+	return
+}
+
+// SetReadMarkers moves the user's fully read marker and/or read receipts in a room. Empty fields of req are left as
+// they are. See https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3roomsroomidread_markers
+func (cli *Client) SetReadMarkers(roomID string, req *request.ReadMarkers) error {
+	return cli.SetReadMarkersContext(context.Background(), roomID, req)
+}
+
+// SetReadMarkersContext is like SetReadMarkers, but the request is bound to ctx.
+func (cli *Client) SetReadMarkersContext(ctx context.Context, roomID string, req *request.ReadMarkers) (err error) {
+	urlPath := cli.BuildURL("rooms", roomID, "read_markers")
+	_, err = cli.MakeRequestContext(ctx, "POST", urlPath, req, nil)
 	return
 }
 
@@ -574,32 +1365,366 @@ func (cli *Client) UserTyping(roomID string, typing bool, timeout int64) (resp *
 // the HTTP response body, or return an error.
 // See http://matrix.org/docs/spec/client_server/r0.2.0.html#get-matrix-client-r0-rooms-roomid-state-eventtype-statekey
 func (cli *Client) StateEvent(roomID, eventType, stateKey string, outContent interface{}) (err error) {
+	return cli.StateEventContext(context.Background(), roomID, eventType, stateKey, outContent)
+}
+
+// StateEventContext is like StateEvent, but the request is bound to ctx.
+func (cli *Client) StateEventContext(ctx context.Context, roomID, eventType, stateKey string, outContent interface{}) (err error) {
 	u := cli.BuildURL("rooms", roomID, "state", eventType, stateKey)
-	_, err = cli.MakeRequest("GET", u, nil, outContent)
+	_, err = cli.MakeRequestContext(ctx, "GET", u, nil, outContent)
 	return
 }
 
-// UploadLink uploads an HTTP URL and then returns an MXC URI.
-func (cli *Client) UploadLink(link string) (*response.MediaUpload, error) {
-	res, err := cli.Client.Get(link)
-	if res != nil {
-		defer res.Body.Close()
+// PublicRooms returns the rooms published in the room directory of server, or of this homeserver if server is empty.
+// Pass the NextBatch of the previous response as since to get the next page, and a limit of 0 to let the homeserver
+// choose the page size. See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3publicrooms
+func (cli *Client) PublicRooms(limit int, since, server string) (*response.PublicRooms, error) {
+	return cli.PublicRoomsContext(context.Background(), limit, since, server)
+}
+
+// PublicRoomsContext is like PublicRooms, but the request is bound to ctx.
+func (cli *Client) PublicRoomsContext(ctx context.Context, limit int, since, server string) (resp *response.PublicRooms, err error) {
+	query := map[string]string{}
+	if limit != 0 {
+		query["limit"] = strconv.Itoa(limit)
 	}
-	if err != nil {
-		return nil, err
+	if since != "" {
+		query["since"] = since
+	}
+	if server != "" {
+		query["server"] = server
 	}
-	return cli.UploadToContentRepo(res.Body, res.Header.Get("Content-Type"), res.ContentLength)
+	urlPath := cli.BuildURLWithQuery([]string{"publicRooms"}, query)
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
+	return
 }
 
-// UploadToContentRepo uploads the given bytes to the content repository and returns an MXC URI.
-// See http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-media-r0-upload
-func (cli *Client) UploadToContentRepo(content io.Reader, contentType string, contentLength int64) (*response.MediaUpload, error) {
-	req, err := http.NewRequest("POST", cli.BuildBaseURL("_matrix/media/r0/upload"), content)
+// PublicRoomsFiltered searches the room directory of server, or of this homeserver if server is empty.
+// See https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3publicrooms
+//
+//	resp, err := cli.PublicRoomsFiltered("", &request.PublicRooms{
+//		Limit:  20,
+//		Filter: &request.PublicRoomsFilter{GenericSearchTerm: "golang"},
+//	})
+func (cli *Client) PublicRoomsFiltered(server string, req *request.PublicRooms) (*response.PublicRooms, error) {
+	return cli.PublicRoomsFilteredContext(context.Background(), server, req)
+}
+
+// PublicRoomsFilteredContext is like PublicRoomsFiltered, but the request is bound to ctx.
+func (cli *Client) PublicRoomsFilteredContext(ctx context.Context, server string, req *request.PublicRooms) (resp *response.PublicRooms, err error) {
+	var urlPath string
+	if server != "" {
+		urlPath = cli.BuildURLWithQuery([]string{"publicRooms"}, map[string]string{
+			"server": server,
+		})
+	} else {
+		urlPath = cli.BuildURL("publicRooms")
+	}
+	_, err = cli.MakeRequestContext(ctx, "POST", urlPath, req, &resp)
+	return
+}
+
+// GetRoomVisibility returns whether the room is published in the room directory, i.e. "public" or "private".
+// See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3directorylistroomroomid
+func (cli *Client) GetRoomVisibility(roomID string) (*response.RoomVisibility, error) {
+	return cli.GetRoomVisibilityContext(context.Background(), roomID)
+}
+
+// GetRoomVisibilityContext is like GetRoomVisibility, but the request is bound to ctx.
+func (cli *Client) GetRoomVisibilityContext(ctx context.Context, roomID string) (resp *response.RoomVisibility, err error) {
+	urlPath := cli.BuildURL("directory", "list", "room", roomID)
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
+	return
+}
+
+// SetRoomVisibility publishes the room in the room directory if visibility is "public", or removes it if it is
+// "private". See https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3directorylistroomroomid
+func (cli *Client) SetRoomVisibility(roomID, visibility string) (*response.SetRoomVisibility, error) {
+	return cli.SetRoomVisibilityContext(context.Background(), roomID, visibility)
+}
+
+// SetRoomVisibilityContext is like SetRoomVisibility, but the request is bound to ctx.
+func (cli *Client) SetRoomVisibilityContext(ctx context.Context, roomID, visibility string) (resp *response.SetRoomVisibility, err error) {
+	urlPath := cli.BuildURL("directory", "list", "room", roomID)
+	_, err = cli.MakeRequestContext(ctx, "PUT", urlPath, &request.RoomVisibility{Visibility: visibility}, &resp)
+	return
+}
+
+// CreateAlias maps a room alias to a room ID. See https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3directoryroomroomalias
+func (cli *Client) CreateAlias(alias, roomID string) (*response.CreateAlias, error) {
+	return cli.CreateAliasContext(context.Background(), alias, roomID)
+}
+
+// CreateAliasContext is like CreateAlias, but the request is bound to ctx.
+func (cli *Client) CreateAliasContext(ctx context.Context, alias, roomID string) (resp *response.CreateAlias, err error) {
+	urlPath := cli.BuildURL("directory", "room", alias)
+	_, err = cli.MakeRequestContext(ctx, "PUT", urlPath, &request.CreateAlias{RoomID: roomID}, &resp)
+	return
+}
+
+// ResolveAlias returns the room ID a room alias maps to, along with servers which can be used to join it.
+// See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3directoryroomroomalias
+func (cli *Client) ResolveAlias(alias string) (*response.ResolveAlias, error) {
+	return cli.ResolveAliasContext(context.Background(), alias)
+}
+
+// ResolveAliasContext is like ResolveAlias, but the request is bound to ctx.
+func (cli *Client) ResolveAliasContext(ctx context.Context, alias string) (resp *response.ResolveAlias, err error) {
+	urlPath := cli.BuildURL("directory", "room", alias)
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
+	return
+}
+
+// DeleteAlias removes a room alias. See https://spec.matrix.org/latest/client-server-api/#delete_matrixclientv3directoryroomroomalias
+func (cli *Client) DeleteAlias(alias string) (*response.DeleteAlias, error) {
+	return cli.DeleteAliasContext(context.Background(), alias)
+}
+
+// DeleteAliasContext is like DeleteAlias, but the request is bound to ctx.
+func (cli *Client) DeleteAliasContext(ctx context.Context, alias string) (resp *response.DeleteAlias, err error) {
+	urlPath := cli.BuildURL("directory", "room", alias)
+	_, err = cli.MakeRequestContext(ctx, "DELETE", urlPath, nil, &resp)
+	return
+}
+
+// RoomAliases returns the local aliases of a room. See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3roomsroomidaliases
+func (cli *Client) RoomAliases(roomID string) (*response.RoomAliases, error) {
+	return cli.RoomAliasesContext(context.Background(), roomID)
+}
+
+// RoomAliasesContext is like RoomAliases, but the request is bound to ctx.
+func (cli *Client) RoomAliasesContext(ctx context.Context, roomID string) (resp *response.RoomAliases, err error) {
+	urlPath := cli.BuildURL("rooms", roomID, "aliases")
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
+	return
+}
+
+// GetAccountData gets the user's global account data of the given type. It will attempt to JSON unmarshal into the
+// given "outContent" struct with the HTTP response body, or return an error.
+// See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3useruseridaccount_datatype
+func (cli *Client) GetAccountData(eventType string, outContent interface{}) error {
+	return cli.GetAccountDataContext(context.Background(), eventType, outContent)
+}
+
+// GetAccountDataContext is like GetAccountData, but the request is bound to ctx.
+func (cli *Client) GetAccountDataContext(ctx context.Context, eventType string, outContent interface{}) (err error) {
+	urlPath := cli.BuildURL("user", cli.UserID, "account_data", eventType)
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, outContent)
+	return
+}
+
+// SetAccountData sets the user's global account data of the given type.
+// See https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3useruseridaccount_datatype
+// contentJSON should be a pointer to something that can be encoded as JSON using json.Marshal.
+func (cli *Client) SetAccountData(eventType string, contentJSON interface{}) error {
+	return cli.SetAccountDataContext(context.Background(), eventType, contentJSON)
+}
+
+// SetAccountDataContext is like SetAccountData, but the request is bound to ctx.
+func (cli *Client) SetAccountDataContext(ctx context.Context, eventType string, contentJSON interface{}) (err error) {
+	urlPath := cli.BuildURL("user", cli.UserID, "account_data", eventType)
+	_, err = cli.MakeRequestContext(ctx, "PUT", urlPath, contentJSON, nil)
+	return
+}
+
+// GetRoomAccountData gets the user's account data of the given type for a single room. It will attempt to JSON
+// unmarshal into the given "outContent" struct with the HTTP response body, or return an error.
+// See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3useruseridroomsroomidaccount_datatype
+func (cli *Client) GetRoomAccountData(roomID, eventType string, outContent interface{}) error {
+	return cli.GetRoomAccountDataContext(context.Background(), roomID, eventType, outContent)
+}
+
+// GetRoomAccountDataContext is like GetRoomAccountData, but the request is bound to ctx.
+func (cli *Client) GetRoomAccountDataContext(ctx context.Context, roomID, eventType string, outContent interface{}) (err error) {
+	urlPath := cli.BuildURL("user", cli.UserID, "rooms", roomID, "account_data", eventType)
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, outContent)
+	return
+}
+
+// SetRoomAccountData sets the user's account data of the given type for a single room.
+// See https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3useruseridroomsroomidaccount_datatype
+// contentJSON should be a pointer to something that can be encoded as JSON using json.Marshal.
+func (cli *Client) SetRoomAccountData(roomID, eventType string, contentJSON interface{}) error {
+	return cli.SetRoomAccountDataContext(context.Background(), roomID, eventType, contentJSON)
+}
+
+// SetRoomAccountDataContext is like SetRoomAccountData, but the request is bound to ctx.
+func (cli *Client) SetRoomAccountDataContext(ctx context.Context, roomID, eventType string, contentJSON interface{}) (err error) {
+	urlPath := cli.BuildURL("user", cli.UserID, "rooms", roomID, "account_data", eventType)
+	_, err = cli.MakeRequestContext(ctx, "PUT", urlPath, contentJSON, nil)
+	return
+}
+
+// GetTags returns the user's tags for a room. See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3useruseridroomsroomidtags
+func (cli *Client) GetTags(roomID string) (*response.Tags, error) {
+	return cli.GetTagsContext(context.Background(), roomID)
+}
+
+// GetTagsContext is like GetTags, but the request is bound to ctx.
+func (cli *Client) GetTagsContext(ctx context.Context, roomID string) (resp *response.Tags, err error) {
+	urlPath := cli.BuildURL("user", cli.UserID, "rooms", roomID, "tags")
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
+	return
+}
+
+// AddTag tags a room, e.g. with event.TagFavourite. order is the position of the room among the rooms with the same
+// tag, from 0 to 1. See https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3useruseridroomsroomidtagstag
+func (cli *Client) AddTag(roomID, tag string, order float64) error {
+	return cli.AddTagContext(context.Background(), roomID, tag, order)
+}
+
+// AddTagContext is like AddTag, but the request is bound to ctx.
+func (cli *Client) AddTagContext(ctx context.Context, roomID, tag string, order float64) (err error) {
+	urlPath := cli.BuildURL("user", cli.UserID, "rooms", roomID, "tags", tag)
+	_, err = cli.MakeRequestContext(ctx, "PUT", urlPath, &event.TagInfo{Order: &order}, nil)
+	return
+}
+
+// RemoveTag removes a tag from a room. See https://spec.matrix.org/latest/client-server-api/#delete_matrixclientv3useruseridroomsroomidtagstag
+func (cli *Client) RemoveTag(roomID, tag string) error {
+	return cli.RemoveTagContext(context.Background(), roomID, tag)
+}
+
+// RemoveTagContext is like RemoveTag, but the request is bound to ctx.
+func (cli *Client) RemoveTagContext(ctx context.Context, roomID, tag string) (err error) {
+	urlPath := cli.BuildURL("user", cli.UserID, "rooms", roomID, "tags", tag)
+	_, err = cli.MakeRequestContext(ctx, "DELETE", urlPath, nil, nil)
+	return
+}
+
+// GetPushRules returns the user's push rules. See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3pushrules
+func (cli *Client) GetPushRules() (*response.PushRules, error) {
+	return cli.GetPushRulesContext(context.Background())
+}
+
+// GetPushRulesContext is like GetPushRules, but the request is bound to ctx.
+func (cli *Client) GetPushRulesContext(ctx context.Context) (resp *response.PushRules, err error) {
+	// the trailing slash is part of the endpoint
+	u, _ := url.Parse(cli.BuildURL("pushrules"))
+	u.Path += "/"
+	_, err = cli.MakeRequestContext(ctx, "GET", u.String(), nil, &resp)
+	return
+}
+
+// AddPushRule adds a push rule of the given kind, e.g. event.PushRuleKindOverride, or replaces the user's rule with
+// the same ID. See https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3pushrulesscopekindruleid
+func (cli *Client) AddPushRule(kind, ruleID string, req *request.PushRule) error {
+	return cli.AddPushRuleContext(context.Background(), kind, ruleID, req)
+}
+
+// AddPushRuleContext is like AddPushRule, but the request is bound to ctx.
+func (cli *Client) AddPushRuleContext(ctx context.Context, kind, ruleID string, req *request.PushRule) (err error) {
+	query := map[string]string{}
+	if req.Before != "" {
+		query["before"] = req.Before
+	}
+	if req.After != "" {
+		query["after"] = req.After
+	}
+	urlPath := cli.BuildURLWithQuery([]string{"pushrules", "global", kind, ruleID}, query)
+	_, err = cli.MakeRequestContext(ctx, "PUT", urlPath, req, nil)
+	return
+}
+
+// DeletePushRule deletes one of the user's push rules. See https://spec.matrix.org/latest/client-server-api/#delete_matrixclientv3pushrulesscopekindruleid
+func (cli *Client) DeletePushRule(kind, ruleID string) error {
+	return cli.DeletePushRuleContext(context.Background(), kind, ruleID)
+}
+
+// DeletePushRuleContext is like DeletePushRule, but the request is bound to ctx.
+func (cli *Client) DeletePushRuleContext(ctx context.Context, kind, ruleID string) (err error) {
+	urlPath := cli.BuildURL("pushrules", "global", kind, ruleID)
+	_, err = cli.MakeRequestContext(ctx, "DELETE", urlPath, nil, nil)
+	return
+}
+
+// SetPushRuleEnabled enables or disables a push rule, including the server default rules.
+// See https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3pushrulesscopekindruleidenabled
+func (cli *Client) SetPushRuleEnabled(kind, ruleID string, enabled bool) error {
+	return cli.SetPushRuleEnabledContext(context.Background(), kind, ruleID, enabled)
+}
+
+// SetPushRuleEnabledContext is like SetPushRuleEnabled, but the request is bound to ctx.
+func (cli *Client) SetPushRuleEnabledContext(ctx context.Context, kind, ruleID string, enabled bool) (err error) {
+	urlPath := cli.BuildURL("pushrules", "global", kind, ruleID, "enabled")
+	_, err = cli.MakeRequestContext(ctx, "PUT", urlPath, &request.PushRuleEnabled{Enabled: enabled}, nil)
+	return
+}
+
+// SetPushRuleActions changes the actions of a push rule, including the server default rules.
+// See https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3pushrulesscopekindruleidactions
+func (cli *Client) SetPushRuleActions(kind, ruleID string, actions event.PushActions) error {
+	return cli.SetPushRuleActionsContext(context.Background(), kind, ruleID, actions)
+}
+
+// SetPushRuleActionsContext is like SetPushRuleActions, but the request is bound to ctx.
+func (cli *Client) SetPushRuleActionsContext(ctx context.Context, kind, ruleID string, actions event.PushActions) (err error) {
+	urlPath := cli.BuildURL("pushrules", "global", kind, ruleID, "actions")
+	_, err = cli.MakeRequestContext(ctx, "PUT", urlPath, &request.PushRuleActions{Actions: actions}, nil)
+	return
+}
+
+// Pushers returns the user's pushers. See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3pushers
+func (cli *Client) Pushers() (*response.Pushers, error) {
+	return cli.PushersContext(context.Background())
+}
+
+// PushersContext is like Pushers, but the request is bound to ctx.
+func (cli *Client) PushersContext(ctx context.Context) (resp *response.Pushers, err error) {
+	urlPath := cli.BuildURL("pushers")
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
+	return
+}
+
+// SetPusher creates a pusher, or updates the pusher with the same app ID and push key.
+// See https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3pushersset
+//
+//	err := cli.SetPusher(&request.Pusher{
+//		PushKey:           token,
+//		Kind:              request.PusherKindHTTP,
+//		AppID:             "com.example.app",
+//		AppDisplayName:    "Example",
+//		DeviceDisplayName: "Phone",
+//		Lang:              "en",
+//		Data:              request.PusherData{URL: "https://push.example.com/_matrix/push/v1/notify"},
+//	})
+func (cli *Client) SetPusher(req *request.Pusher) error {
+	return cli.SetPusherContext(context.Background(), req)
+}
+
+// SetPusherContext is like SetPusher, but the request is bound to ctx.
+func (cli *Client) SetPusherContext(ctx context.Context, req *request.Pusher) (err error) {
+	urlPath := cli.BuildURL("pushers", "set")
+	_, err = cli.MakeRequestContext(ctx, "POST", urlPath, req, nil)
+	return
+}
+
+// DeletePusher deletes the pusher with the given app ID and push key.
+// See https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3pushersset
+func (cli *Client) DeletePusher(appID, pushKey string) error {
+	return cli.DeletePusherContext(context.Background(), appID, pushKey)
+}
+
+// DeletePusherContext is like DeletePusher, but the request is bound to ctx.
+func (cli *Client) DeletePusherContext(ctx context.Context, appID, pushKey string) (err error) {
+	urlPath := cli.BuildURL("pushers", "set")
+	// a null kind deletes the pusher
+	req := map[string]interface{}{"app_id": appID, "pushkey": pushKey, "kind": nil}
+	_, err = cli.MakeRequestContext(ctx, "POST", urlPath, req, nil)
+	return
+}
+
+// UploadLink uploads an HTTP URL and then returns an MXC URI.
+func (cli *Client) UploadLink(link string) (*response.MediaUpload, error) {
+	return cli.UploadLinkContext(context.Background(), link)
+}
+
+// UploadLinkContext is like UploadLink, but the request is bound to ctx.
+func (cli *Client) UploadLinkContext(ctx context.Context, link string) (*response.MediaUpload, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", link, nil)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", contentType)
-	req.ContentLength = contentLength
 	res, err := cli.Client.Do(req)
 	if res != nil {
 		defer res.Body.Close()
@@ -607,24 +1732,22 @@ func (cli *Client) UploadToContentRepo(content io.Reader, contentType string, co
 	if err != nil {
 		return nil, err
 	}
-	if res.StatusCode != 200 {
-		contents, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			return nil, HTTPError{
-				Message: "Upload request failed - Failed to read response body: " + err.Error(),
-				Code:    res.StatusCode,
-			}
-		}
-		return nil, HTTPError{
-			Message: "Upload request failed: " + string(contents),
-			Code:    res.StatusCode,
-		}
-	}
-	var m response.MediaUpload
-	if err := json.NewDecoder(res.Body).Decode(&m); err != nil {
-		return nil, err
-	}
-	return &m, nil
+	return cli.UploadToContentRepoContext(ctx, res.Body, res.Header.Get("Content-Type"), res.ContentLength)
+}
+
+// UploadToContentRepo uploads the given bytes to the content repository and returns an MXC URI.
+// See http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-media-r0-upload
+func (cli *Client) UploadToContentRepo(content io.Reader, contentType string, contentLength int64) (*response.MediaUpload, error) {
+	return cli.UploadToContentRepoContext(context.Background(), content, contentType, contentLength)
+}
+
+// UploadToContentRepoContext is like UploadToContentRepo, but the request is bound to ctx.
+func (cli *Client) UploadToContentRepoContext(ctx context.Context, content io.Reader, contentType string, contentLength int64) (*response.MediaUpload, error) {
+	return cli.UploadContext(ctx, &UploadRequest{
+		Content:       content,
+		ContentType:   contentType,
+		ContentLength: contentLength,
+	})
 }
 
 // JoinedMembers returns a map of joined room members. See TODO-SPEC. https://github.com/matrix-org/synapse/pull/1680
@@ -632,8 +1755,13 @@ func (cli *Client) UploadToContentRepo(content io.Reader, contentType string, co
 // In general, usage of this API is discouraged in favour of /sync, as calling this API can race with incoming membership changes.
 // This API is primarily designed for application services which may want to efficiently look up joined members in a room.
 func (cli *Client) JoinedMembers(roomID string) (resp *response.JoinedMembers, err error) {
+	return cli.JoinedMembersContext(context.Background(), roomID)
+}
+
+// JoinedMembersContext is like JoinedMembers, but the request is bound to ctx.
+func (cli *Client) JoinedMembersContext(ctx context.Context, roomID string) (resp *response.JoinedMembers, err error) {
 	u := cli.BuildURL("rooms", roomID, "joined_members")
-	_, err = cli.MakeRequest("GET", u, nil, &resp)
+	_, err = cli.MakeRequestContext(ctx, "GET", u, nil, &resp)
 	return
 }
 
@@ -642,8 +1770,13 @@ func (cli *Client) JoinedMembers(roomID string) (resp *response.JoinedMembers, e
 // In general, usage of this API is discouraged in favour of /sync, as calling this API can race with incoming membership changes.
 // This API is primarily designed for application services which may want to efficiently look up joined rooms.
 func (cli *Client) JoinedRooms() (resp *response.JoinedRooms, err error) {
+	return cli.JoinedRoomsContext(context.Background())
+}
+
+// JoinedRoomsContext is like JoinedRooms, but the request is bound to ctx.
+func (cli *Client) JoinedRoomsContext(ctx context.Context) (resp *response.JoinedRooms, err error) {
 	u := cli.BuildURL("joined_rooms")
-	_, err = cli.MakeRequest("GET", u, nil, &resp)
+	_, err = cli.MakeRequestContext(ctx, "GET", u, nil, &resp)
 	return
 }
 
@@ -651,6 +1784,11 @@ func (cli *Client) JoinedRooms() (resp *response.JoinedRooms, err error) {
 // pagination query parameters to paginate history in the room.
 // See https://matrix.org/docs/spec/client_server/r0.2.0.html#get-matrix-client-r0-rooms-roomid-messages
 func (cli *Client) Messages(roomID, from, to string, dir rune, limit int) (resp *response.Messages, err error) {
+	return cli.MessagesContext(context.Background(), roomID, from, to, dir, limit)
+}
+
+// MessagesContext is like Messages, but the request is bound to ctx.
+func (cli *Client) MessagesContext(ctx context.Context, roomID, from, to string, dir rune, limit int) (resp *response.Messages, err error) {
 	query := map[string]string{
 		"from": from,
 		"dir":  string(dir),
@@ -663,15 +1801,173 @@ func (cli *Client) Messages(roomID, from, to string, dir rune, limit int) (resp
 	}
 
 	urlPath := cli.BuildURLWithQuery([]string{"rooms", roomID, "messages"}, query)
-	_, err = cli.MakeRequest("GET", urlPath, nil, &resp)
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
+	return
+}
+
+// Notifications returns the events the user was notified about, newest first. from is the next_token of the previous
+// page, or "" for the first page. If limit is 0 the server default is used. only can be "highlight" to only return
+// highlighted notifications. See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3notifications
+func (cli *Client) Notifications(from string, limit int, only string) (*response.Notifications, error) {
+	return cli.NotificationsContext(context.Background(), from, limit, only)
+}
+
+// NotificationsContext is like Notifications, but the request is bound to ctx.
+func (cli *Client) NotificationsContext(ctx context.Context, from string, limit int, only string) (resp *response.Notifications, err error) {
+	query := map[string]string{}
+	if from != "" {
+		query["from"] = from
+	}
+	if limit != 0 {
+		query["limit"] = strconv.Itoa(limit)
+	}
+	if only != "" {
+		query["only"] = only
+	}
+	urlPath := cli.BuildURLWithQuery([]string{"notifications"}, query)
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
+	return
+}
+
+// Search searches the events of the rooms the user is in. nextBatch is the next_batch of the previous page of
+// results, or "" for the first page. See https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3search
+//
+//	resp, err := cli.Search("", &request.Search{
+//		SearchCategories: request.SearchCategories{
+//			RoomEvents: &request.SearchRoomEvents{
+//				SearchTerm:   "invoice",
+//				OrderBy:      request.SearchOrderRecent,
+//				EventContext: &request.SearchEventContext{BeforeLimit: 1, AfterLimit: 1},
+//			},
+//		},
+//	})
+func (cli *Client) Search(nextBatch string, req *request.Search) (*response.Search, error) {
+	return cli.SearchContext(context.Background(), nextBatch, req)
+}
+
+// SearchContext is like Search, but the request is bound to ctx.
+func (cli *Client) SearchContext(ctx context.Context, nextBatch string, req *request.Search) (resp *response.Search, err error) {
+	query := map[string]string{}
+	if nextBatch != "" {
+		query["next_batch"] = nextBatch
+	}
+	urlPath := cli.BuildURLWithQuery([]string{"search"}, query)
+	_, err = cli.MakeRequestContext(ctx, "POST", urlPath, req, &resp)
+	return
+}
+
+// GetEvent fetches a single event from a room. See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3roomsroomideventeventid
+func (cli *Client) GetEvent(roomID, eventID string) (*event.Event, error) {
+	return cli.GetEventContext(context.Background(), roomID, eventID)
+}
+
+// GetEventContext is like GetEvent, but the request is bound to ctx.
+func (cli *Client) GetEventContext(ctx context.Context, roomID, eventID string) (resp *event.Event, err error) {
+	urlPath := cli.BuildURL("rooms", roomID, "event", eventID)
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
+	if resp != nil && resp.RoomID == "" {
+		resp.RoomID = roomID
+	}
+	return
+}
+
+// EventContext fetches an event along with up to limit events before and after it, and the room state at that point.
+// If limit is 0 the server default is used. filter is an optional RoomEventFilter for the surrounding events.
+// See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3roomsroomidcontexteventid
+func (cli *Client) EventContext(roomID, eventID string, limit int, filter json.RawMessage) (*response.EventContext, error) {
+	return cli.EventContextContext(context.Background(), roomID, eventID, limit, filter)
+}
+
+// EventContextContext is like EventContext, but the request is bound to ctx.
+func (cli *Client) EventContextContext(ctx context.Context, roomID, eventID string, limit int, filter json.RawMessage) (resp *response.EventContext, err error) {
+	query := map[string]string{}
+	if limit != 0 {
+		query["limit"] = strconv.Itoa(limit)
+	}
+	if len(filter) > 0 {
+		query["filter"] = string(filter)
+	}
+	urlPath := cli.BuildURLWithQuery([]string{"rooms", roomID, "context", eventID}, query)
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
 	return
 }
 
 // TurnServer returns turn server details and credentials for the client to use when initiating calls.
 // See http://matrix.org/docs/spec/client_server/r0.2.0.html#get-matrix-client-r0-voip-turnserver
 func (cli *Client) TurnServer() (resp *response.TurnServer, err error) {
+	return cli.TurnServerContext(context.Background())
+}
+
+// TurnServerContext is like TurnServer, but the request is bound to ctx.
+func (cli *Client) TurnServerContext(ctx context.Context) (resp *response.TurnServer, err error) {
 	urlPath := cli.BuildURL("voip", "turnServer")
-	_, err = cli.MakeRequest("GET", urlPath, nil, &resp)
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
+	return
+}
+
+// Devices returns the devices of the current user. See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3devices
+func (cli *Client) Devices() (*response.Devices, error) {
+	return cli.DevicesContext(context.Background())
+}
+
+// DevicesContext is like Devices, but the request is bound to ctx.
+func (cli *Client) DevicesContext(ctx context.Context) (resp *response.Devices, err error) {
+	urlPath := cli.BuildURL("devices")
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
+	return
+}
+
+// GetDevice returns a single device of the current user. See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3devicesdeviceid
+func (cli *Client) GetDevice(deviceID string) (*response.Device, error) {
+	return cli.GetDeviceContext(context.Background(), deviceID)
+}
+
+// GetDeviceContext is like GetDevice, but the request is bound to ctx.
+func (cli *Client) GetDeviceContext(ctx context.Context, deviceID string) (resp *response.Device, err error) {
+	urlPath := cli.BuildURL("devices", deviceID)
+	_, err = cli.MakeRequestContext(ctx, "GET", urlPath, nil, &resp)
+	return
+}
+
+// UpdateDevice sets the display name of a device of the current user. See https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3devicesdeviceid
+func (cli *Client) UpdateDevice(deviceID, displayName string) error {
+	return cli.UpdateDeviceContext(context.Background(), deviceID, displayName)
+}
+
+// UpdateDeviceContext is like UpdateDevice, but the request is bound to ctx.
+func (cli *Client) UpdateDeviceContext(ctx context.Context, deviceID, displayName string) (err error) {
+	urlPath := cli.BuildURL("devices", deviceID)
+	_, err = cli.MakeRequestContext(ctx, "PUT", urlPath, &request.UpdateDevice{DisplayName: displayName}, nil)
+	return
+}
+
+// DeleteDevice deletes a device of the current user, logging it out. The homeserver requires user-interactive
+// authentication, which is completed with uia. See https://spec.matrix.org/latest/client-server-api/#delete_matrixclientv3devicesdeviceid
+func (cli *Client) DeleteDevice(deviceID string, uia *UIA) error {
+	return cli.DeleteDeviceContext(context.Background(), deviceID, uia)
+}
+
+// DeleteDeviceContext is like DeleteDevice, but the requests are bound to ctx.
+func (cli *Client) DeleteDeviceContext(ctx context.Context, deviceID string, uia *UIA) (err error) {
+	urlPath := cli.BuildURL("devices", deviceID)
+	_, err = cli.MakeUIARequestContext(ctx, "DELETE", urlPath, uia, func(auth *request.AuthData) interface{} {
+		return &request.DeleteDevice{Auth: auth}
+	}, nil)
+	return
+}
+
+// DeleteDevices deletes several devices of the current user at once, logging them out. The homeserver requires
+// user-interactive authentication, which is completed with uia. See https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3delete_devices
+func (cli *Client) DeleteDevices(deviceIDs []string, uia *UIA) error {
+	return cli.DeleteDevicesContext(context.Background(), deviceIDs, uia)
+}
+
+// DeleteDevicesContext is like DeleteDevices, but the requests are bound to ctx.
+func (cli *Client) DeleteDevicesContext(ctx context.Context, deviceIDs []string, uia *UIA) (err error) {
+	urlPath := cli.BuildURL("delete_devices")
+	_, err = cli.MakeUIARequestContext(ctx, "POST", urlPath, uia, func(auth *request.AuthData) interface{} {
+		return &request.DeleteDevices{Devices: deviceIDs, Auth: auth}
+	}, nil)
 	return
 }
 
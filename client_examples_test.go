@@ -5,6 +5,8 @@ import (
 	"net/http"
 
 	"github.com/rbns/gomatrix/event"
+	"github.com/rbns/gomatrix/request"
+	"github.com/rbns/gomatrix/response"
 )
 
 func Example_sync() {
@@ -109,13 +111,35 @@ func ExampleClient_JoinRoom_alias() {
 // Login to a local homeserver and set the user ID and access token on success.
 func ExampleClient_Login() {
 	cli, _ := NewClient("http://localhost:8008", "", "")
-	resp, err := cli.Login(&ReqLogin{
-		Type:     "m.login.password",
-		User:     "alice",
-		Password: "wonderland",
+	resp, err := cli.Login(&request.Login{
+		Type:       request.AuthTypePassword,
+		Identifier: request.NewUserIdentifier("alice"),
+		Password:   "wonderland",
 	})
 	if err != nil {
 		panic(err)
 	}
 	cli.SetCredentials(resp.UserID, resp.AccessToken)
 }
+
+// Log in with whichever method the homeserver supports.
+func ExampleClient_LoginFlows() {
+	cli, _ := NewClient("http://localhost:8008", "", "")
+	flows, err := cli.LoginFlows()
+	if err != nil {
+		panic(err)
+	}
+	var resp *response.Login
+	if flows.HasFlow(request.AuthTypePassword) {
+		resp, err = cli.LoginWithPassword(request.NewUserIdentifier("alice"), "wonderland")
+	} else if flows.HasFlow(request.AuthTypeSSO) {
+		resp, err = cli.LoginSSO("", func(ssoURL string) error {
+			fmt.Println("Open this URL in your browser to log in:", ssoURL)
+			return nil
+		})
+	}
+	if err != nil {
+		panic(err)
+	}
+	cli.SetCredentials(resp.UserID, resp.AccessToken)
+}
@@ -2,10 +2,21 @@ package gomatrix
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/rbns/gomatrix/event"
+	"github.com/rbns/gomatrix/request"
+	"github.com/rbns/gomatrix/response"
 )
 
 func TestClient_LeaveRoom(t *testing.T) {
@@ -84,6 +95,587 @@ func TestClient_StateEvent(t *testing.T) {
 	}
 }
 
+func TestClient_SyncContextCancelled(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "GET" && req.URL.Path == "/_matrix/client/r0/sync" {
+			// block like a long-poll until the request is cancelled
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+	cli.Store.SaveFilterID(cli.UserID, "1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- cli.SyncContext(ctx)
+	}()
+	cancel()
+
+	select {
+	case err := <-errs:
+		if err != context.Canceled {
+			t.Fatalf("SyncContext: got %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SyncContext: did not return after the context was cancelled")
+	}
+}
+
+func TestClient_SendMessageEventRateLimited(t *testing.T) {
+	var paths []string
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "PUT" && strings.HasPrefix(req.URL.Path, "/_matrix/client/r0/rooms/!foo:bar/send/m.room.message/") {
+			paths = append(paths, req.URL.Path)
+			if len(paths) < 3 {
+				return &http.Response{
+					StatusCode: 429,
+					Body:       ioutil.NopCloser(bytes.NewBufferString(`{"errcode":"M_LIMIT_EXCEEDED","error":"Too many requests","retry_after_ms":1}`)),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"event_id":"$ev:bar"}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+	var retries int
+	cli.RetryPolicy = &RetryPolicy{
+		MaxRetries: 2,
+		MinBackoff: time.Millisecond,
+		OnRetry: func(attempt int, wait time.Duration, err HTTPError) {
+			retries++
+			if err.Code != 429 {
+				t.Errorf("OnRetry: got code %d, want 429", err.Code)
+			}
+		},
+	}
+
+	resp, err := cli.SendText("!foo:bar", "hello")
+	if err != nil {
+		t.Fatalf("SendText: error, got %s", err.Error())
+	}
+	if resp.EventID != "$ev:bar" {
+		t.Fatalf("SendText: got event ID %s, want $ev:bar", resp.EventID)
+	}
+	if retries != 2 {
+		t.Fatalf("SendText: got %d retries, want 2", retries)
+	}
+	if paths[0] != paths[1] || paths[1] != paths[2] {
+		t.Fatalf("SendText: retries used different transaction IDs: %v", paths)
+	}
+}
+
+func TestClient_RateLimitedWithoutRetryPolicy(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 429,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{"errcode":"M_LIMIT_EXCEEDED","error":"Too many requests","retry_after_ms":2000}`)),
+		}, nil
+	})
+
+	_, err := cli.SendText("!foo:bar", "hello")
+	httpErr, ok := err.(HTTPError)
+	if !ok || httpErr.Code != 429 {
+		t.Fatalf("SendText: got %v, want a 429 HTTPError", err)
+	}
+	if respErr, ok := httpErr.WrappedError.(response.Error); !ok || respErr.RetryAfterMs != 2000 {
+		t.Fatalf("SendText: got wrapped error %#v, want retry_after_ms 2000", httpErr.WrappedError)
+	}
+}
+
+func TestClient_ErrorCodes(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 401,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{"errcode":"M_UNKNOWN_TOKEN","error":"Token expired","soft_logout":true}`)),
+		}, nil
+	})
+
+	_, err := cli.JoinedRooms()
+	if !errors.Is(err, response.ErrUnknownToken) {
+		t.Fatalf("JoinedRooms: got %v, want M_UNKNOWN_TOKEN", err)
+	}
+	if errors.Is(err, response.ErrForbidden) {
+		t.Fatalf("JoinedRooms: %v unexpectedly matched M_FORBIDDEN", err)
+	}
+	var respErr response.Error
+	if !errors.As(err, &respErr) || !respErr.SoftLogout {
+		t.Fatalf("JoinedRooms: got %#v, want soft_logout to be set", respErr)
+	}
+}
+
+func TestClient_LoginWithPassword(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "POST" && req.URL.Path == "/_matrix/client/r0/login" {
+			body, _ := ioutil.ReadAll(req.Body)
+			want := `{"type":"m.login.password","identifier":{"type":"m.id.user","user":"alice"},"password":"wonderland"}`
+			if string(body) != want {
+				t.Errorf("LoginWithPassword: got body %s, want %s", body, want)
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"user_id":"@alice:test.gomatrix.org","access_token":"abc","device_id":"DEV"}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	resp, err := cli.LoginWithPassword(request.NewUserIdentifier("alice"), "wonderland")
+	if err != nil {
+		t.Fatalf("LoginWithPassword: error, got %s", err.Error())
+	}
+	if resp.AccessToken != "abc" {
+		t.Fatalf("LoginWithPassword: got access token %s, want abc", resp.AccessToken)
+	}
+}
+
+func TestClient_SSORedirectURL(t *testing.T) {
+	cli := mockClient(nil)
+	got := cli.SSORedirectURL("oidc-github", "http://127.0.0.1:1234/")
+	want := "https://test.gomatrix.org/_matrix/client/r0/login/sso/redirect/oidc-github?redirectUrl=http%3A%2F%2F127.0.0.1%3A1234%2F"
+	if got != want {
+		t.Fatalf("SSORedirectURL: got %s, want %s", got, want)
+	}
+}
+
+func TestClient_RefreshOnExpiredToken(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "POST" && req.URL.Path == "/_matrix/client/v3/refresh" {
+			body, _ := ioutil.ReadAll(req.Body)
+			if string(body) != `{"refresh_token":"refresh1"}` {
+				t.Errorf("RefreshTokens: got body %s", body)
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"access_token":"access2","refresh_token":"refresh2","expires_in_ms":60000}`)),
+			}, nil
+		}
+		if req.Method == "GET" && req.URL.Path == "/_matrix/client/r0/joined_rooms" {
+			if req.URL.Query().Get("access_token") != "access2" {
+				return &http.Response{
+					StatusCode: 401,
+					Body:       ioutil.NopCloser(bytes.NewBufferString(`{"errcode":"M_UNKNOWN_TOKEN","error":"Access token has expired","soft_logout":true}`)),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"joined_rooms":["!foo:bar"]}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+	cli.RefreshToken = "refresh1"
+	var refreshed *response.Refresh
+	cli.OnTokensRefreshed = func(resp *response.Refresh) {
+		refreshed = resp
+	}
+
+	resp, err := cli.JoinedRooms()
+	if err != nil {
+		t.Fatalf("JoinedRooms: error, got %s", err.Error())
+	}
+	if len(resp.JoinedRooms) != 1 {
+		t.Fatalf("JoinedRooms: got %v, want [!foo:bar]", resp.JoinedRooms)
+	}
+	if accessToken, refreshToken := cli.Tokens(); accessToken != "access2" || refreshToken != "refresh2" {
+		t.Fatalf("JoinedRooms: got tokens %s/%s, want access2/refresh2", accessToken, refreshToken)
+	}
+	if refreshed == nil || refreshed.AccessToken != "access2" {
+		t.Fatalf("OnTokensRefreshed: got %v, want the new tokens", refreshed)
+	}
+}
+
+func TestClient_NoRefreshOnHardLogout(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/_matrix/client/v3/refresh" {
+			t.Errorf("RefreshTokens: called after a hard logout")
+		}
+		return &http.Response{
+			StatusCode: 401,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{"errcode":"M_UNKNOWN_TOKEN","error":"Device deleted"}`)),
+		}, nil
+	})
+	cli.RefreshToken = "refresh1"
+
+	_, err := cli.JoinedRooms()
+	if !IsHardLogout(err) {
+		t.Fatalf("JoinedRooms: got %v, want a hard logout", err)
+	}
+}
+
+func TestClient_RefreshFailureReturnsOriginalError(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/_matrix/client/v3/refresh" {
+			return &http.Response{
+				StatusCode: 401,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"errcode":"M_UNKNOWN_TOKEN","error":"Refresh token has been used"}`)),
+			}, nil
+		}
+		return &http.Response{
+			StatusCode: 401,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{"errcode":"M_UNKNOWN_TOKEN","error":"Access token has expired","soft_logout":true}`)),
+		}, nil
+	})
+	cli.RefreshToken = "refresh1"
+
+	_, err := cli.JoinedRooms()
+	if !IsSoftLogout(err) {
+		t.Fatalf("JoinedRooms: got %v, want the original soft logout", err)
+	}
+}
+
+func TestClient_ConcurrentRefresh(t *testing.T) {
+	var refreshes int32
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/_matrix/client/v3/refresh" {
+			atomic.AddInt32(&refreshes, 1)
+			time.Sleep(10 * time.Millisecond)
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"access_token":"access2","refresh_token":"refresh2"}`)),
+			}, nil
+		}
+		if req.URL.Query().Get("access_token") != "access2" {
+			return &http.Response{
+				StatusCode: 401,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"errcode":"M_UNKNOWN_TOKEN","error":"Access token has expired","soft_logout":true}`)),
+			}, nil
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{"joined_rooms":[]}`)),
+		}, nil
+	})
+	cli.RefreshToken = "refresh1"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cli.JoinedRooms(); err != nil {
+				t.Errorf("JoinedRooms: error, got %s", err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+	if refreshes != 1 {
+		t.Fatalf("RefreshTokens: called %d times, want 1", refreshes)
+	}
+	if accessToken, _ := cli.Tokens(); accessToken != "access2" {
+		t.Fatalf("Tokens: got access token %s, want access2", accessToken)
+	}
+}
+
+func TestIsSoftLogout(t *testing.T) {
+	soft := HTTPError{Code: 401, WrappedError: response.Error{ErrCode: "M_UNKNOWN_TOKEN", SoftLogout: true}}
+	hard := HTTPError{Code: 401, WrappedError: response.Error{ErrCode: "M_UNKNOWN_TOKEN"}}
+	if !IsSoftLogout(soft) || IsHardLogout(soft) {
+		t.Errorf("IsSoftLogout: soft_logout=true was not a soft logout")
+	}
+	if IsSoftLogout(hard) || !IsHardLogout(hard) {
+		t.Errorf("IsHardLogout: soft_logout=false was not a hard logout")
+	}
+}
+
+func TestClient_DeleteDevices(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "POST" && req.URL.Path == "/_matrix/client/r0/delete_devices" {
+			var body request.DeleteDevices
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				return nil, err
+			}
+			if len(body.Devices) != 2 {
+				t.Errorf("DeleteDevices: got devices %v, want 2", body.Devices)
+			}
+			if body.Auth == nil {
+				return &http.Response{
+					StatusCode: 401,
+					Body:       ioutil.NopCloser(bytes.NewBufferString(`{"session":"sess","flows":[{"stages":["m.login.password"]}]}`)),
+				}, nil
+			}
+			if body.Auth.Type != request.AuthTypePassword || body.Auth.Password != "wonderland" || body.Auth.Identifier.User != "@user:test.gomatrix.org" {
+				t.Errorf("DeleteDevices: got auth %+v", body.Auth)
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	uia := NewUIA()
+	uia.Handle(request.AuthTypePassword, PasswordStage(request.NewUserIdentifier(cli.UserID), "wonderland"))
+	if err := cli.DeleteDevices([]string{"ABC", "DEF"}, uia); err != nil {
+		t.Fatalf("DeleteDevices: error, got %s", err.Error())
+	}
+}
+
+func TestClient_ChangePassword(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "POST" && req.URL.Path == "/_matrix/client/r0/account/password" {
+			body, _ := ioutil.ReadAll(req.Body)
+			if !bytes.Contains(body, []byte(`"auth"`)) {
+				return &http.Response{
+					StatusCode: 401,
+					Body:       ioutil.NopCloser(bytes.NewBufferString(`{"session":"sess","flows":[{"stages":["m.login.password"]}]}`)),
+				}, nil
+			}
+			want := `{"new_password":"looking-glass","logout_devices":false,"auth":{"type":"m.login.password","session":"sess","identifier":{"type":"m.id.user","user":"alice"},"password":"wonderland"}}`
+			if string(body) != want {
+				t.Errorf("ChangePassword: got body %s, want %s", body, want)
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	uia := NewUIA()
+	uia.Handle(request.AuthTypePassword, PasswordStage(request.NewUserIdentifier("alice"), "wonderland"))
+	if err := cli.ChangePassword("looking-glass", false, uia); err != nil {
+		t.Fatalf("ChangePassword: error, got %s", err.Error())
+	}
+}
+
+func TestClient_SetRoomDisplayName(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/_matrix/client/r0/rooms/!foo:bar/state/m.room.member/@user:test.gomatrix.org" {
+			switch req.Method {
+			case "GET":
+				return &http.Response{
+					StatusCode: 200,
+					Body:       ioutil.NopCloser(bytes.NewBufferString(`{"membership":"join","displayname":"User","avatar_url":"mxc://bar/avatar","reason":"hi"}`)),
+				}, nil
+			case "PUT":
+				body, _ := ioutil.ReadAll(req.Body)
+				want := `{"avatar_url":"mxc://bar/avatar","displayname":"Puppet","membership":"join","reason":"hi"}`
+				if string(body) != want {
+					t.Errorf("SetRoomDisplayName: got body %s, want %s", body, want)
+				}
+				return &http.Response{
+					StatusCode: 200,
+					Body:       ioutil.NopCloser(bytes.NewBufferString(`{"event_id":"$ev:bar"}`)),
+				}, nil
+			}
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	if _, err := cli.SetRoomDisplayName("!foo:bar", "Puppet"); err != nil {
+		t.Fatalf("SetRoomDisplayName: error, got %s", err.Error())
+	}
+}
+
+func TestClient_PublicRooms(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "GET" && req.URL.Path == "/_matrix/client/r0/publicRooms" {
+			q := req.URL.Query()
+			if q.Get("limit") != "10" || q.Get("since") != "p1" || q.Get("server") != "example.org" {
+				t.Errorf("PublicRooms: got query %s", req.URL.RawQuery)
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body: ioutil.NopCloser(bytes.NewBufferString(`{"chunk":[{"room_id":"!foo:bar","name":"Foo","num_joined_members":3,` +
+					`"world_readable":true,"guest_can_join":false}],"next_batch":"p2"}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	resp, err := cli.PublicRooms(10, "p1", "example.org")
+	if err != nil {
+		t.Fatalf("PublicRooms: error, got %s", err.Error())
+	}
+	if len(resp.Chunk) != 1 || resp.Chunk[0].RoomID != "!foo:bar" || resp.Chunk[0].NumJoinedMembers != 3 {
+		t.Fatalf("PublicRooms: got chunk %+v", resp.Chunk)
+	}
+	if resp.NextBatch != "p2" {
+		t.Fatalf("PublicRooms: got next_batch %s, want p2", resp.NextBatch)
+	}
+}
+
+func TestClient_ResolveAlias(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "GET" && req.URL.Path == "/_matrix/client/r0/directory/room/#foo:bar" {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"room_id":"!foo:bar","servers":["bar","baz"]}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	resp, err := cli.ResolveAlias("#foo:bar")
+	if err != nil {
+		t.Fatalf("ResolveAlias: error, got %s", err.Error())
+	}
+	if resp.RoomID != "!foo:bar" || len(resp.Servers) != 2 {
+		t.Fatalf("ResolveAlias: got %+v", resp)
+	}
+}
+
+func TestClient_AddTag(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "PUT" && req.URL.Path == "/_matrix/client/r0/user/@user:test.gomatrix.org/rooms/!foo:bar/tags/m.lowpriority" {
+			body, _ := ioutil.ReadAll(req.Body)
+			if string(body) != `{"order":0.25}` {
+				t.Errorf("AddTag: got body %s", body)
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	if err := cli.AddTag("!foo:bar", event.TagLowPriority, 0.25); err != nil {
+		t.Fatalf("AddTag: error, got %s", err.Error())
+	}
+}
+
+func TestClient_SendReceipt(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "POST" && req.URL.Path == "/_matrix/client/r0/rooms/!foo:bar/receipt/m.read.private/$event:bar" {
+			body, _ := ioutil.ReadAll(req.Body)
+			if string(body) != `{"thread_id":"main"}` {
+				t.Errorf("SendReceipt: got body %s", body)
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	if err := cli.SendReceipt("!foo:bar", "$event:bar", event.ReceiptTypeReadPrivate, event.ThreadIDMain); err != nil {
+		t.Fatalf("SendReceipt: error, got %s", err.Error())
+	}
+}
+
+func TestClient_GetPushRules(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "GET" && req.URL.Path == "/_matrix/client/r0/pushrules/" {
+			return &http.Response{
+				StatusCode: 200,
+				Body: ioutil.NopCloser(bytes.NewBufferString(`{"global":{"content":[{"rule_id":".m.rule.contains_user_name",
+					"default":true,"enabled":true,"pattern":"user","actions":["notify",{"set_tweak":"sound","value":"default"}]}]}}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	resp, err := cli.GetPushRules()
+	if err != nil {
+		t.Fatalf("GetPushRules: error, got %s", err.Error())
+	}
+	if len(resp.Global.Content) != 1 || resp.Global.Content[0].Pattern != "user" {
+		t.Fatalf("GetPushRules: got content rules %+v", resp.Global.Content)
+	}
+	if actions := resp.Global.Content[0].Actions; !actions.Notify() || actions.Sound() != "default" {
+		t.Fatalf("GetPushRules: got actions %+v", actions)
+	}
+}
+
+func TestClient_DeletePusher(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "POST" && req.URL.Path == "/_matrix/client/r0/pushers/set" {
+			body, _ := ioutil.ReadAll(req.Body)
+			if string(body) != `{"app_id":"com.example.app","kind":null,"pushkey":"abc"}` {
+				t.Errorf("DeletePusher: got body %s", body)
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	if err := cli.DeletePusher("com.example.app", "abc"); err != nil {
+		t.Fatalf("DeletePusher: error, got %s", err.Error())
+	}
+}
+
+func TestClient_Search(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "POST" && req.URL.Path == "/_matrix/client/r0/search" {
+			if req.URL.Query().Get("next_batch") != "page2" {
+				t.Errorf("Search: got next_batch %q", req.URL.Query().Get("next_batch"))
+			}
+			body, _ := ioutil.ReadAll(req.Body)
+			if string(body) != `{"search_categories":{"room_events":{"search_term":"invoice","order_by":"recent"}}}` {
+				t.Errorf("Search: got body %s", body)
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body: ioutil.NopCloser(bytes.NewBufferString(`{"search_categories":{"room_events":{"count":1,"highlights":["invoice"],
+					"results":[{"rank":0.5,"result":{"type":"m.room.message","event_id":"$result:bar","room_id":"!foo:bar",
+					"content":{"msgtype":"m.text","body":"the invoice"}},"context":{"events_before":[{"type":"m.room.message",
+					"event_id":"$before:bar","content":{"msgtype":"m.text","body":"hi"}}],"events_after":[]}}]}}}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	resp, err := cli.Search("page2", &request.Search{
+		SearchCategories: request.SearchCategories{
+			RoomEvents: &request.SearchRoomEvents{SearchTerm: "invoice", OrderBy: request.SearchOrderRecent},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Search: error, got %s", err.Error())
+	}
+	results := resp.SearchCategories.RoomEvents.Results
+	if len(results) != 1 || results[0].Result.ID != "$result:bar" {
+		t.Fatalf("Search: got results %+v", results)
+	}
+	if msg, ok := results[0].Result.Content.(event.TextMessage); !ok || msg.Body != "the invoice" {
+		t.Fatalf("Search: got content %+v", results[0].Result.Content)
+	}
+	if results[0].Context == nil || len(results[0].Context.EventsBefore) != 1 {
+		t.Fatalf("Search: got context %+v", results[0].Context)
+	}
+}
+
+func TestClient_EventContext(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "GET" && req.URL.Path == "/_matrix/client/r0/rooms/!foo:bar/context/$event:bar" {
+			if req.URL.Query().Get("limit") != "2" || req.URL.Query().Get("filter") != `{"types":["m.room.message"]}` {
+				t.Errorf("EventContext: got query %s", req.URL.RawQuery)
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body: ioutil.NopCloser(bytes.NewBufferString(`{"start":"t1","end":"t2",
+					"event":{"type":"m.room.message","event_id":"$event:bar","content":{"msgtype":"m.text","body":"reported"}},
+					"events_before":[{"type":"m.room.message","event_id":"$before:bar","content":{"msgtype":"m.text","body":"hi"}}],
+					"events_after":[],
+					"state":[{"type":"m.room.name","state_key":"","content":{"name":"Room"}}]}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	resp, err := cli.EventContext("!foo:bar", "$event:bar", 2, json.RawMessage(`{"types":["m.room.message"]}`))
+	if err != nil {
+		t.Fatalf("EventContext: error, got %s", err.Error())
+	}
+	if resp.Start != "t1" || resp.End != "t2" || resp.Event.ID != "$event:bar" || len(resp.EventsBefore) != 1 || len(resp.State) != 1 {
+		t.Fatalf("EventContext: got %+v", resp)
+	}
+	if msg, ok := resp.Event.Content.(event.TextMessage); !ok || msg.Body != "reported" {
+		t.Fatalf("EventContext: got event content %+v", resp.Event.Content)
+	}
+}
+
 func mockClient(fn func(*http.Request) (*http.Response, error)) *Client {
 	mrt := MockRoundTripper{
 		RT: fn,
@@ -0,0 +1,106 @@
+package gomatrix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rbns/gomatrix/response"
+)
+
+// DiscoverClientAPI looks up the homeserver for the given server name using /.well-known/matrix/client and returns a
+// Client for it. See https://spec.matrix.org/latest/client-server-api/#well-known-uri
+//
+// If the server does not publish a well-known file, https://<serverName> is assumed to be the homeserver. The homeserver
+// (and the identity server, if one is advertised) is checked to be reachable before the Client is returned. The Client
+// has no user ID or access token set. See Login and SetCredentials.
+//
+// httpClient is used to make the requests, and is set as the Client's underlying HTTP client. If it is nil,
+// http.DefaultClient is used.
+func DiscoverClientAPI(serverName string, httpClient *http.Client) (*Client, error) {
+	return DiscoverClientAPIContext(context.Background(), serverName, httpClient)
+}
+
+// DiscoverClientAPIContext is like DiscoverClientAPI, but the requests are bound to ctx.
+func DiscoverClientAPIContext(ctx context.Context, serverName string, httpClient *http.Client) (*Client, error) {
+	cli, err := NewClient("https://"+serverName, "", "")
+	if err != nil {
+		return nil, err
+	}
+	if httpClient != nil {
+		cli.Client = httpClient
+	}
+
+	var wellKnown *response.ClientWellKnown
+	_, err = cli.MakeRequestContext(ctx, "GET", cli.BuildBaseURL(".well-known", "matrix", "client"), nil, &wellKnown)
+	var httpErr HTTPError
+	if errors.As(err, &httpErr) && httpErr.Code == 404 {
+		// No well-known file: assume the server name is also the homeserver.
+		if _, err = cli.VersionsContext(ctx); err != nil {
+			return nil, fmt.Errorf("%s does not look like a homeserver: %w", cli.HomeserverURL, err)
+		}
+		return cli, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch well-known for %s: %w", serverName, err)
+	}
+
+	if wellKnown == nil || wellKnown.Homeserver.BaseURL == "" {
+		return nil, fmt.Errorf("well-known for %s has no m.homeserver base_url", serverName)
+	}
+	if cli.HomeserverURL, err = parseBaseURL(wellKnown.Homeserver.BaseURL); err != nil {
+		return nil, fmt.Errorf("well-known for %s has an invalid m.homeserver base_url: %w", serverName, err)
+	}
+	if _, err = cli.VersionsContext(ctx); err != nil {
+		return nil, fmt.Errorf("%s does not look like a homeserver: %w", cli.HomeserverURL, err)
+	}
+
+	if wellKnown.IdentityServer != nil {
+		if cli.IdentityServerURL, err = parseBaseURL(wellKnown.IdentityServer.BaseURL); err != nil {
+			return nil, fmt.Errorf("well-known for %s has an invalid m.identity_server base_url: %w", serverName, err)
+		}
+		isURL := *cli.IdentityServerURL
+		isURL.Path = strings.TrimSuffix(isURL.Path, "/") + "/_matrix/identity/v2"
+		if _, err = cli.MakeRequestContext(ctx, "GET", isURL.String(), nil, nil); err != nil {
+			return nil, fmt.Errorf("%s does not look like an identity server: %w", cli.IdentityServerURL, err)
+		}
+	}
+	return cli, nil
+}
+
+// DiscoverClientAPIForUser is like DiscoverClientAPI, but looks up the homeserver from the server name of the
+// given user ID. The returned Client has its UserID set, but no access token.
+func DiscoverClientAPIForUser(userID string, httpClient *http.Client) (*Client, error) {
+	return DiscoverClientAPIForUserContext(context.Background(), userID, httpClient)
+}
+
+// DiscoverClientAPIForUserContext is like DiscoverClientAPIForUser, but the requests are bound to ctx.
+func DiscoverClientAPIForUserContext(ctx context.Context, userID string, httpClient *http.Client) (*Client, error) {
+	serverName, err := ExtractUserServerName(userID)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := DiscoverClientAPIContext(ctx, serverName, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	cli.UserID = userID
+	if s, ok := cli.Syncer.(*DefaultSyncer); ok {
+		s.UserID = userID
+	}
+	return cli, nil
+}
+
+// parseBaseURL parses a base_url from a well-known file, which must be an absolute http(s) URL.
+func parseBaseURL(baseURL string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%s is not an absolute http(s) URL", baseURL)
+	}
+	return u, nil
+}
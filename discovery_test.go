@@ -0,0 +1,89 @@
+package gomatrix
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/rbns/gomatrix/response"
+)
+
+func TestDiscoverClientAPIForUser(t *testing.T) {
+	httpClient := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Host + req.URL.Path {
+		case "example.org/.well-known/matrix/client":
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"m.homeserver":{"base_url":"https://matrix.example.org/"}}`)),
+			}, nil
+		case "matrix.example.org/_matrix/client/versions":
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"versions":["r0.6.1"]}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL)
+	})
+
+	cli, err := DiscoverClientAPIForUser("@alice:example.org", httpClient)
+	if err != nil {
+		t.Fatalf("DiscoverClientAPIForUser: error, got %s", err.Error())
+	}
+	if cli.HomeserverURL.String() != "https://matrix.example.org" {
+		t.Fatalf("DiscoverClientAPIForUser: got homeserver %s, want https://matrix.example.org", cli.HomeserverURL)
+	}
+	if cli.UserID != "@alice:example.org" {
+		t.Fatalf("DiscoverClientAPIForUser: got user ID %s, want @alice:example.org", cli.UserID)
+	}
+	if cli.Client != httpClient {
+		t.Fatal("DiscoverClientAPIForUser: the Client does not use the given HTTP client")
+	}
+}
+
+func TestDiscoverClientAPI_NoWellKnown(t *testing.T) {
+	httpClient := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Host + req.URL.Path {
+		case "example.org/.well-known/matrix/client":
+			return &http.Response{
+				StatusCode: 404,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`Not Found`)),
+			}, nil
+		case "example.org/_matrix/client/versions":
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"versions":["r0.6.1"]}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL)
+	})
+
+	cli, err := DiscoverClientAPI("example.org", httpClient)
+	if err != nil {
+		t.Fatalf("DiscoverClientAPI: error, got %s", err.Error())
+	}
+	if cli.HomeserverURL.String() != "https://example.org" {
+		t.Fatalf("DiscoverClientAPI: got homeserver %s, want https://example.org", cli.HomeserverURL)
+	}
+}
+
+func TestDiscoverClientAPI_WrapsErrors(t *testing.T) {
+	httpClient := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 403,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{"errcode":"M_FORBIDDEN","error":"Go away"}`)),
+		}, nil
+	})
+
+	_, err := DiscoverClientAPI("example.org", httpClient)
+	if !errors.Is(err, response.ErrForbidden) {
+		t.Fatalf("DiscoverClientAPI: got %v, want M_FORBIDDEN", err)
+	}
+}
+
+// mockHTTPClient returns an HTTP client which uses fn to make requests.
+func mockHTTPClient(fn func(*http.Request) (*http.Response, error)) *http.Client {
+	return &http.Client{Transport: MockRoundTripper{RT: fn}}
+}
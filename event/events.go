@@ -35,6 +35,11 @@ const (
 	eventRoomThirdPartyInvite  = "m.room.third_party_invite"
 	eventRoomGuestAccess       = "m.room.guest_access"
 	eventDirect                = "m.direct"
+	eventIgnoredUserList       = "m.ignored_user_list"
+	eventFullyRead             = "m.fully_read"
+	eventTag                   = "m.tag"
+	eventPushRules             = "m.push_rules"
+	eventReaction              = "m.reaction"
 	messageText                = "m.text"
 	messageEmote               = "m.emote"
 	messageNotice              = "m.notice"
@@ -59,6 +64,27 @@ type Event struct {
 	RoomID    string      `json:"room_id"`             // The room the event was sent to. May be nil (e.g. for presence)
 	Content   interface{} `json:"content"`             // The JSON content of the event.
 	Redacts   string      `json:"redacts,omitempty"`   // The event ID that was redacted if a m.room.redaction event
+
+	RawContent json.RawMessage `json:"-"` // The JSON content of the event as it was received, before decoding into Content.
+}
+
+// RelatesTo returns the m.relates_to field of the event's content, or nil if the event isn't related to another.
+// The content is taken from RawContent if the event was decoded from JSON, and from Content otherwise.
+func (e *Event) RelatesTo() *RelatesTo {
+	raw := e.RawContent
+	if raw == nil {
+		var err error
+		if raw, err = json.Marshal(e.Content); err != nil {
+			return nil
+		}
+	}
+	var content struct {
+		RelatesTo *RelatesTo `json:"m.relates_to"`
+	}
+	if err := json.Unmarshal(raw, &content); err != nil {
+		return nil
+	}
+	return content.RelatesTo
 }
 
 // jsonEvent is used while unmarshalling to access Content as RawMessage
@@ -85,6 +111,7 @@ func (e *Event) UnmarshalJSON(data []byte) error {
 	e.Timestamp = je.Timestamp
 	e.ID = je.ID
 	e.RoomID = je.RoomID
+	e.RawContent = je.Content
 
 	// unmarshal the content into the matching type
 	switch je.Type {
@@ -285,7 +312,37 @@ func (e *Event) UnmarshalJSON(data []byte) error {
 		}
 		e.Content = x
 	case eventDirect:
-		x := RoomAliases{}
+		x := Direct{}
+		if err := json.Unmarshal(je.Content, &x); err != nil {
+			return err
+		}
+		e.Content = x
+	case eventIgnoredUserList:
+		x := IgnoredUserList{}
+		if err := json.Unmarshal(je.Content, &x); err != nil {
+			return err
+		}
+		e.Content = x
+	case eventFullyRead:
+		x := FullyRead{}
+		if err := json.Unmarshal(je.Content, &x); err != nil {
+			return err
+		}
+		e.Content = x
+	case eventTag:
+		x := Tag{}
+		if err := json.Unmarshal(je.Content, &x); err != nil {
+			return err
+		}
+		e.Content = x
+	case eventPushRules:
+		x := PushRules{}
+		if err := json.Unmarshal(je.Content, &x); err != nil {
+			return err
+		}
+		e.Content = x
+	case eventReaction:
+		x := Reaction{}
 		if err := json.Unmarshal(je.Content, &x); err != nil {
 			return err
 		}
@@ -340,6 +397,24 @@ type RoomPowerLevels struct {
 	StateDefault  int            `json:"state_default,omitempty"`
 	Users         map[string]int `json:"users,omitempty"`
 	UsersDefault  int            `json:"users_default,omitempty"`
+	Notifications map[string]int `json:"notifications,omitempty"`
+}
+
+// UserLevel returns the power level of the given user.
+func (pl RoomPowerLevels) UserLevel(userID string) int {
+	if level, ok := pl.Users[userID]; ok {
+		return level
+	}
+	return pl.UsersDefault
+}
+
+// NotificationLevel returns the power level needed to trigger the given kind of notification, e.g. "room" for
+// @room notifications. It defaults to 50.
+func (pl RoomPowerLevels) NotificationLevel(key string) int {
+	if level, ok := pl.Notifications[key]; ok {
+		return level
+	}
+	return 50
 }
 
 type RoomRedaction struct {
@@ -424,29 +499,83 @@ type Typing struct {
 	UserIDs []string `json:"user_ids"`
 }
 
-type Receipt map[string]struct {
-	MRead map[string]struct {
-		Ts int `json:"ts"`
-	} `json:"m.read"`
+// Receipt is the content of an m.receipt event. It maps event IDs to receipt types (e.g. ReceiptTypeRead) to the
+// user IDs which sent a receipt of that type for the event.
+// See https://spec.matrix.org/latest/client-server-api/#receipts
+type Receipt map[string]map[string]map[string]ReceiptInfo
+
+// ReceiptInfo is the information attached to a single user's receipt.
+type ReceiptInfo struct {
+	Ts       int64  `json:"ts"`                  // The unix timestamp in milliseconds when the receipt was sent
+	ThreadID string `json:"thread_id,omitempty"` // The thread the receipt is for, or "" if it is unthreaded
 }
 
+// Receipt types for Receipt and Client.SendReceipt. Private read receipts are only sent to the user's own clients.
+const (
+	ReceiptTypeRead        = "m.read"
+	ReceiptTypeReadPrivate = "m.read.private"
+)
+
+// ThreadIDMain is the thread ID of receipts for events which are not part of any thread.
+const ThreadIDMain = "main"
+
 type Presence struct {
 	AvatarURL       string `json:"avatar_url"`
 	Displayname     string `json:"displayname"`
 	LastActiveAgo   int    `json:"last_active_ago"`
 	Presence        string `json:"presence"`
+	StatusMsg       string `json:"status_msg,omitempty"`
 	CurrentlyActive bool   `json:"currently_active"`
 	UserID          string `json:"user_id"`
 }
 
-//type Direct map[string][]string
+// Presence states for the Presence field of Presence.
+const (
+	PresenceOnline      = "online"
+	PresenceOffline     = "offline"
+	PresenceUnavailable = "unavailable"
+)
+
+// Direct is the Content of a "m.direct" account data event. It maps user IDs to the IDs of the direct chat rooms
+// with them.
+type Direct map[string][]string
+
+// IgnoredUserList is the Content of a "m.ignored_user_list" account data event.
+type IgnoredUserList struct {
+	IgnoredUsers map[string]struct{} `json:"ignored_users"`
+}
+
+// FullyRead is the Content of a "m.fully_read" room account data event.
+type FullyRead struct {
+	EventID string `json:"event_id"`
+}
+
+// Tag is the Content of a "m.tag" room account data event.
+type Tag struct {
+	Tags map[string]TagInfo `json:"tags"`
+}
+
+// Tags defined by the specification. Other tags should use a reverse-DNS name, or "u." for user-defined tags.
+// See https://spec.matrix.org/latest/client-server-api/#room-tagging
+const (
+	TagFavourite    = "m.favourite"
+	TagLowPriority  = "m.lowpriority"
+	TagServerNotice = "m.server_notice"
+)
+
+// TagInfo is the information attached to a single tag of a room.
+type TagInfo struct {
+	Order *float64 `json:"order,omitempty"` // The position of the room among rooms with the same tag, from 0 to 1.
+}
 
 // TextMessage is the contents of a Matrix formated message event.
 type TextMessage struct {
-	Body          string `json:"body"`
-	MsgType       string `json:"msgtype"`
-	Format        string `json:"format"`
-	FormattedBody string `json:"formatted_body"`
+	Body          string       `json:"body"`
+	MsgType       string       `json:"msgtype"`
+	Format        string       `json:"format"`
+	FormattedBody string       `json:"formatted_body"`
+	RelatesTo     *RelatesTo   `json:"m.relates_to,omitempty"`
+	NewContent    *TextMessage `json:"m.new_content,omitempty"` // The replacement content of an edit
 }
 
 var htmlRegex = regexp.MustCompile("<[^<]+?>")
@@ -467,7 +596,9 @@ func (m *TextMessage) MarshalJSON() ([]byte, error) {
 		m.Body = m.body()
 	}
 
-	return json.Marshal(m)
+	// marshal through a type without this method, so that it isn't called recursively
+	type textMessage TextMessage
+	return json.Marshal((*textMessage)(m))
 }
 
 type EmoteMessage struct {
@@ -481,25 +612,34 @@ func (m *EmoteMessage) MarshalJSON() ([]byte, error) {
 		m.Body = m.TextMessage.body()
 	}
 
-	return json.Marshal(m)
+	// marshal the embedded TextMessage through a type without methods, as TextMessage.MarshalJSON would set the
+	// msgtype to m.text
+	type textMessage TextMessage
+	return json.Marshal((*textMessage)(&m.TextMessage))
 }
 
 type NoticeMessage struct {
-	Body    string `json:"body"`
-	MsgType string `json:"msgtype"`
+	Body      string     `json:"body"`
+	MsgType   string     `json:"msgtype"`
+	RelatesTo *RelatesTo `json:"m.relates_to,omitempty"`
 }
 
 func (m *NoticeMessage) MarshalJSON() ([]byte, error) {
 	m.MsgType = messageNotice
-	return json.Marshal(m)
+	type noticeMessage NoticeMessage
+	return json.Marshal((*noticeMessage)(m))
 }
 
 // ImageInfo contains info about an image - http://matrix.org/docs/spec/client_server/r0.2.0.html#m-image
 type ImageInfo struct {
-	Height   uint   `json:"h,omitempty"`
-	Width    uint   `json:"w,omitempty"`
-	Mimetype string `json:"mimetype,omitempty"`
-	Size     uint   `json:"size,omitempty"`
+	Height        uint       `json:"h,omitempty"`
+	Width         uint       `json:"w,omitempty"`
+	Mimetype      string     `json:"mimetype,omitempty"`
+	Size          uint       `json:"size,omitempty"`
+	ThumbnailURL  string     `json:"thumbnail_url,omitempty"`
+	ThumbnailInfo *ImageInfo `json:"thumbnail_info,omitempty"`
+	// A BlurHash of the image, for clients to show while it loads. See https://github.com/matrix-org/matrix-spec-proposals/pull/2448
+	Blurhash string `json:"xyz.amorgan.blurhash,omitempty"`
 }
 
 // VideoInfo contains info about a video - http://matrix.org/docs/spec/client_server/r0.2.0.html#m-video
@@ -523,7 +663,8 @@ type VideoMessage struct {
 
 func (m *VideoMessage) MarshalJSON() ([]byte, error) {
 	m.MsgType = messageVideo
-	return json.Marshal(m)
+	type videoMessage VideoMessage
+	return json.Marshal((*videoMessage)(m))
 }
 
 // ImageMessage is an m.image event
@@ -536,7 +677,8 @@ type ImageMessage struct {
 
 func (m *ImageMessage) MarshalJSON() ([]byte, error) {
 	m.MsgType = messageImage
-	return json.Marshal(m)
+	type imageMessage ImageMessage
+	return json.Marshal((*imageMessage)(m))
 }
 
 type FileInfo struct {
@@ -556,7 +698,8 @@ type FileMessage struct {
 
 func (m *FileMessage) MarshalJSON() ([]byte, error) {
 	m.MsgType = messageFile
-	return json.Marshal(m)
+	type fileMessage FileMessage
+	return json.Marshal((*fileMessage)(m))
 }
 
 type LocationInfo struct {
@@ -573,23 +716,192 @@ type LocationMessage struct {
 
 func (m *LocationMessage) MarshalJSON() ([]byte, error) {
 	m.MsgType = messageLocation
-	return json.Marshal(m)
+	type locationMessage LocationMessage
+	return json.Marshal((*locationMessage)(m))
 }
 
 type AudioInfo struct {
-	Duration int    `json:"duration"`
-	Mimetype string `json:"mimetype"`
-	Size     int    `json:"size"`
+	Duration int    `json:"duration,omitempty"` // The duration in milliseconds
+	Mimetype string `json:"mimetype,omitempty"`
+	Size     int    `json:"size,omitempty"`
 }
 
 type AudioMessage struct {
 	Body    string    `json:"body"`
 	MsgType string    `json:"msgtype"`
-	Info    AudioInfo `json:"audio_info"`
+	Info    AudioInfo `json:"info"`
 	URL     string    `json:"url"`
 }
 
 func (m *AudioMessage) MarshalJSON() ([]byte, error) {
 	m.MsgType = messageAudio
-	return json.Marshal(m)
+	type audioMessage AudioMessage
+	return json.Marshal((*audioMessage)(m))
+}
+
+// RelatesTo is the "m.relates_to" field of an event's content, which relates it to another event.
+// See https://spec.matrix.org/latest/client-server-api/#forming-relationships-between-events
+type RelatesTo struct {
+	RelType   string     `json:"rel_type,omitempty"` // One of the RelType constants, or "" for a plain reply
+	EventID   string     `json:"event_id,omitempty"`
+	Key       string     `json:"key,omitempty"`           // The reaction, for m.annotation
+	InReplyTo *InReplyTo `json:"m.in_reply_to,omitempty"` // The event replied to
+	// For m.thread: true if InReplyTo is only set for clients which don't support threads, rather than being a
+	// real reply.
+	IsFallingBack bool `json:"is_falling_back,omitempty"`
+}
+
+// InReplyTo is the event a message replies to.
+type InReplyTo struct {
+	EventID string `json:"event_id"`
+}
+
+// Relation types.
+const (
+	RelTypeReplace    = "m.replace"
+	RelTypeAnnotation = "m.annotation"
+	RelTypeThread     = "m.thread"
+	RelTypeReference  = "m.reference"
+)
+
+// Reaction is the Content of a "m.reaction" event.
+type Reaction struct {
+	RelatesTo RelatesTo `json:"m.relates_to"`
+}
+
+// PushRules is the content of an m.push_rules account data event.
+// See https://spec.matrix.org/latest/client-server-api/#push-rules
+type PushRules struct {
+	Global PushRuleset `json:"global"`
+}
+
+// PushRuleset is a set of push rules of each kind, in the order they are evaluated.
+type PushRuleset struct {
+	Override  []PushRule `json:"override,omitempty"`
+	Content   []PushRule `json:"content,omitempty"`
+	Room      []PushRule `json:"room,omitempty"`
+	Sender    []PushRule `json:"sender,omitempty"`
+	Underride []PushRule `json:"underride,omitempty"`
+}
+
+// Push rule kinds, in the order they are evaluated.
+const (
+	PushRuleKindOverride  = "override"
+	PushRuleKindContent   = "content"
+	PushRuleKindRoom      = "room"
+	PushRuleKindSender    = "sender"
+	PushRuleKindUnderride = "underride"
+)
+
+// PushRule is a single push rule. Content rules have a Pattern instead of Conditions, and room and sender rules have
+// neither: their RuleID is the room or user ID they match.
+type PushRule struct {
+	RuleID     string          `json:"rule_id"`
+	Default    bool            `json:"default"`
+	Enabled    bool            `json:"enabled"`
+	Actions    PushActions     `json:"actions"`
+	Conditions []PushCondition `json:"conditions,omitempty"`
+	Pattern    string          `json:"pattern,omitempty"`
+}
+
+// PushCondition is a condition of an override or underride push rule.
+type PushCondition struct {
+	Kind    string      `json:"kind"`
+	Key     string      `json:"key,omitempty"`     // The dotted path of the event field, for event_match and event_property_is
+	Pattern string      `json:"pattern,omitempty"` // The glob pattern, for event_match
+	Is      string      `json:"is,omitempty"`      // The comparison, e.g. ">=2", for room_member_count
+	Value   interface{} `json:"value,omitempty"`   // The exact value, for event_property_is
+}
+
+// Push condition kinds.
+const (
+	PushCondEventMatch                   = "event_match"
+	PushCondContainsDisplayName          = "contains_display_name"
+	PushCondRoomMemberCount              = "room_member_count"
+	PushCondSenderNotificationPermission = "sender_notification_permission"
+	PushCondEventPropertyIs              = "event_property_is"
+)
+
+// PushAction is a single action of a push rule: either a plain action such as PushActionNotify, or a tweak which
+// sets Tweak to Value.
+type PushAction struct {
+	Action string
+	Tweak  string
+	Value  interface{}
+}
+
+// Push actions and tweaks.
+const (
+	PushActionNotify     = "notify"
+	PushActionDontNotify = "dont_notify"
+	PushActionCoalesce   = "coalesce"
+	PushActionSetTweak   = "set_tweak"
+
+	PushTweakSound     = "sound"
+	PushTweakHighlight = "highlight"
+)
+
+// MarshalJSON encodes a plain action as a string and a tweak as a set_tweak object.
+func (a PushAction) MarshalJSON() ([]byte, error) {
+	if a.Action != PushActionSetTweak {
+		return json.Marshal(a.Action)
+	}
+	tweak := map[string]interface{}{"set_tweak": a.Tweak}
+	if a.Value != nil {
+		tweak["value"] = a.Value
+	}
+	return json.Marshal(tweak)
+}
+
+// UnmarshalJSON decodes either a plain action or a set_tweak object.
+func (a *PushAction) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &a.Action); err == nil {
+		return nil
+	}
+	var tweak struct {
+		SetTweak string      `json:"set_tweak"`
+		Value    interface{} `json:"value"`
+	}
+	if err := json.Unmarshal(data, &tweak); err != nil {
+		return err
+	}
+	a.Action = PushActionSetTweak
+	a.Tweak = tweak.SetTweak
+	a.Value = tweak.Value
+	return nil
+}
+
+// PushActions are the actions of a push rule.
+type PushActions []PushAction
+
+// Notify returns true if the actions cause a notification.
+func (actions PushActions) Notify() bool {
+	for _, a := range actions {
+		if a.Action == PushActionNotify || a.Action == PushActionCoalesce {
+			return true
+		}
+	}
+	return false
+}
+
+// Highlight returns true if the actions set the highlight tweak. A highlight tweak without a value counts as true.
+func (actions PushActions) Highlight() bool {
+	for _, a := range actions {
+		if a.Action == PushActionSetTweak && a.Tweak == PushTweakHighlight {
+			highlight, ok := a.Value.(bool)
+			return highlight || !ok
+		}
+	}
+	return false
+}
+
+// Sound returns the sound to play for the notification, or "" if the actions don't set one.
+func (actions PushActions) Sound() string {
+	for _, a := range actions {
+		if a.Action == PushActionSetTweak && a.Tweak == PushTweakSound {
+			sound, _ := a.Value.(string)
+			return sound
+		}
+	}
+	return ""
 }
@@ -0,0 +1,350 @@
+package gomatrix
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rbns/gomatrix/response"
+)
+
+// Thumbnail resizing methods.
+const (
+	ThumbnailCrop  = "crop"  // Crop the image to exactly the requested size
+	ThumbnailScale = "scale" // Scale the image to fit within the requested size, keeping the aspect ratio
+)
+
+// Media is a file downloaded from the content repository. Body must be closed by the caller.
+type Media struct {
+	Body          io.ReadCloser
+	ContentType   string
+	ContentLength int64  // -1 if unknown
+	Filename      string // The filename from the Content-Disposition header, if any
+}
+
+// Download downloads the file with the given MXC URI, e.g. the URL of an event.ImageMessage.
+// See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv1mediadownloadservernamemediaid
+//
+//	media, err := cli.Download(msg.URL)
+//	if err != nil {
+//		return err
+//	}
+//	defer media.Body.Close()
+//	_, err = io.Copy(f, media.Body)
+func (cli *Client) Download(mxc string) (*Media, error) {
+	return cli.DownloadContext(context.Background(), mxc)
+}
+
+// DownloadContext is like Download, but the request is bound to ctx.
+func (cli *Client) DownloadContext(ctx context.Context, mxc string) (*Media, error) {
+	m, err := ParseMXC(mxc)
+	if err != nil {
+		return nil, err
+	}
+	return cli.downloadMedia(ctx, cli.mediaURL(nil, "download", m.ServerName, m.MediaID))
+}
+
+// Thumbnail downloads a thumbnail of the file with the given MXC URI. The homeserver picks the closest size it has
+// to width and height, resized with method: ThumbnailCrop or ThumbnailScale.
+// See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv1mediathumbnailservernamemediaid
+func (cli *Client) Thumbnail(mxc string, width, height int, method string) (*Media, error) {
+	return cli.ThumbnailContext(context.Background(), mxc, width, height, method)
+}
+
+// ThumbnailContext is like Thumbnail, but the request is bound to ctx.
+func (cli *Client) ThumbnailContext(ctx context.Context, mxc string, width, height int, method string) (*Media, error) {
+	m, err := ParseMXC(mxc)
+	if err != nil {
+		return nil, err
+	}
+	query := map[string]string{
+		"width":  strconv.Itoa(width),
+		"height": strconv.Itoa(height),
+	}
+	if method != "" {
+		query["method"] = method
+	}
+	return cli.downloadMedia(ctx, cli.mediaURL(query, "thumbnail", m.ServerName, m.MediaID))
+}
+
+// PreviewURL returns the OpenGraph data of a web page, as fetched by the homeserver. ts is the unix timestamp in
+// milliseconds of the version of the page to return, or 0 for the latest.
+// See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv1mediapreview_url
+func (cli *Client) PreviewURL(link string, ts int64) (*response.PreviewURL, error) {
+	return cli.PreviewURLContext(context.Background(), link, ts)
+}
+
+// PreviewURLContext is like PreviewURL, but the request is bound to ctx.
+func (cli *Client) PreviewURLContext(ctx context.Context, link string, ts int64) (resp *response.PreviewURL, err error) {
+	query := map[string]string{"url": link}
+	if ts != 0 {
+		query["ts"] = strconv.FormatInt(ts, 10)
+	}
+	_, err = cli.MakeRequestContext(ctx, "GET", cli.mediaURL(query, "preview_url"), nil, &resp)
+	return
+}
+
+// MediaConfig returns the configuration of the content repository, such as the maximum upload size.
+// See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv1mediaconfig
+func (cli *Client) MediaConfig() (*response.MediaConfig, error) {
+	return cli.MediaConfigContext(context.Background())
+}
+
+// MediaConfigContext is like MediaConfig, but the request is bound to ctx.
+func (cli *Client) MediaConfigContext(ctx context.Context) (resp *response.MediaConfig, err error) {
+	_, err = cli.MakeRequestContext(ctx, "GET", cli.mediaURL(nil, "config"), nil, &resp)
+	return
+}
+
+// mediaURL builds the URL of a content repository endpoint, using the authenticated endpoints if the Client is
+// configured to.
+func (cli *Client) mediaURL(query map[string]string, urlPath ...string) string {
+	u, _ := url.Parse(cli.BuildBaseURL(cli.mediaPath(urlPath...)...))
+	q := u.Query()
+	for k, v := range query {
+		q.Set(k, v)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// mediaPath prefixes the path of a content repository endpoint.
+func (cli *Client) mediaPath(urlPath ...string) []string {
+	prefix := []string{"_matrix", "media", "v3"}
+	if cli.AuthenticatedMedia {
+		prefix = []string{"_matrix", "client", "v1", "media"}
+	}
+	return append(prefix, urlPath...)
+}
+
+// downloadMedia makes a GET request for a file, retrying and refreshing the access token like MakeRequest. Unlike
+// MakeRequest, the body is not read.
+func (cli *Client) downloadMedia(ctx context.Context, httpURL string) (*Media, error) {
+	res, _, err := cli.doWithRetries(ctx, "GET", httpURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	media := &Media{
+		Body:          res.Body,
+		ContentType:   res.Header.Get("Content-Type"),
+		ContentLength: res.ContentLength,
+	}
+	if _, params, err := mime.ParseMediaType(res.Header.Get("Content-Disposition")); err == nil {
+		media.Filename = params["filename"]
+	}
+	return media, nil
+}
+
+// mediaError returns the HTTPError for a failed content repository request.
+func mediaError(res *http.Response, msg string) error {
+	contents, _ := ioutil.ReadAll(res.Body)
+	var wrap error
+	var respErr response.Error
+	if _ = json.Unmarshal(contents, &respErr); respErr.ErrCode != "" {
+		wrap = respErr
+	}
+	if wrap == nil {
+		msg = msg + ": " + string(contents)
+	}
+	return HTTPError{
+		Code:         res.StatusCode,
+		Message:      msg,
+		WrappedError: wrap,
+	}
+}
+
+// UploadRequest is a file to upload to the content repository with Upload.
+type UploadRequest struct {
+	Content       io.Reader
+	ContentType   string // Defaults to application/octet-stream
+	ContentLength int64  // The size of Content in bytes, or 0 or -1 if unknown. Content is streamed either way.
+	Filename      string // The name of the file, which the homeserver will send when the file is downloaded
+
+	// An MXC URI reserved with CreateMXC to upload the file to. If it is empty, the homeserver picks a new one.
+	MXC MXCURI
+
+	// OnProgress is called, if set, whenever more of Content has been sent, with the number of bytes sent so far and
+	// ContentLength, or -1 if it is unknown. It may be called from another goroutine.
+	OnProgress func(sent, total int64)
+}
+
+// Upload uploads a file to the content repository and returns its MXC URI.
+// See https://spec.matrix.org/latest/client-server-api/#post_matrixmediav3upload
+//
+// The file is checked against the homeserver's m.upload.size limit (see MediaConfig) before it is sent if its size
+// is known, and while it is sent otherwise. An upload which is too large fails with an error matching
+// response.ErrTooLarge.
+//
+//	f, err := os.Open("recording.ogg")
+//	...
+//	resp, err := cli.Upload(&gomatrix.UploadRequest{
+//		Content:       f,
+//		ContentType:   "audio/ogg",
+//		ContentLength: stat.Size(),
+//		Filename:      "recording.ogg",
+//		OnProgress: func(sent, total int64) {
+//			fmt.Printf("%d%%\n", sent*100/total)
+//		},
+//	})
+func (cli *Client) Upload(req *UploadRequest) (*response.MediaUpload, error) {
+	return cli.UploadContext(context.Background(), req)
+}
+
+// UploadContext is like Upload, but the requests are bound to ctx.
+func (cli *Client) UploadContext(ctx context.Context, req *UploadRequest) (*response.MediaUpload, error) {
+	total := req.ContentLength
+	if total <= 0 {
+		total = -1
+	}
+	limit := cli.uploadSizeLimit(ctx)
+	if limit > 0 && total > limit {
+		return nil, fmt.Errorf("upload of %d bytes exceeds the homeserver's limit of %d bytes: %w", total, limit, response.ErrTooLarge)
+	}
+
+	method := "POST"
+	urlPath := []string{"_matrix", "media", "v3", "upload"}
+	if !req.MXC.IsEmpty() {
+		method = "PUT"
+		urlPath = append(urlPath, req.MXC.ServerName, req.MXC.MediaID)
+	}
+	u, _ := url.Parse(cli.BuildBaseURL(urlPath...))
+	if req.Filename != "" {
+		q := u.Query()
+		q.Set("filename", req.Filename)
+		u.RawQuery = q.Encode()
+	}
+
+	body := &uploadReader{r: req.Content, total: total, limit: limit, onProgress: req.OnProgress}
+	httpReq, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	contentType := req.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	if total > 0 {
+		httpReq.ContentLength = total
+	}
+	res, err := cli.Client.Do(httpReq)
+	if res != nil {
+		defer res.Body.Close()
+	}
+	if err := body.tooLarge(); err != nil {
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode/100 != 2 {
+		return nil, mediaError(res, "Upload request failed")
+	}
+
+	var m response.MediaUpload
+	if err := json.NewDecoder(res.Body).Decode(&m); err != nil {
+		return nil, err
+	}
+	if m.ContentURI == "" {
+		// uploads to a reserved URI return an empty object
+		m.ContentURI = req.MXC.String()
+	}
+	return &m, nil
+}
+
+// CreateMXC reserves an MXC URI to upload a file to later with Upload, e.g. so that a message referring to the file
+// can be sent while it is still uploading. See https://spec.matrix.org/latest/client-server-api/#post_matrixmediav1create
+//
+//	created, err := cli.CreateMXC()
+//	...
+//	mxc, err := gomatrix.ParseMXC(created.ContentURI)
+//	...
+//	go cli.Upload(&gomatrix.UploadRequest{Content: f, MXC: mxc})
+func (cli *Client) CreateMXC() (*response.CreateMXC, error) {
+	return cli.CreateMXCContext(context.Background())
+}
+
+// CreateMXCContext is like CreateMXC, but the request is bound to ctx.
+func (cli *Client) CreateMXCContext(ctx context.Context) (resp *response.CreateMXC, err error) {
+	urlPath := cli.BuildBaseURL("_matrix", "media", "v1", "create")
+	_, err = cli.MakeRequestContext(ctx, "POST", urlPath, nil, &resp)
+	return
+}
+
+// How long to wait before fetching the media config again after it couldn't be fetched.
+const mediaConfigRetryInterval = 5 * time.Minute
+
+// uploadSizeLimit returns the homeserver's maximum upload size, or 0 if it is unknown. The media config is fetched
+// once and then cached. If it can't be fetched, e.g. because the homeserver doesn't have the endpoint, the limit is
+// unknown until mediaConfigRetryInterval has passed.
+func (cli *Client) uploadSizeLimit(ctx context.Context) int64 {
+	cli.mediaConfigMutex.Lock()
+	config, retryAt := cli.mediaConfig, cli.mediaConfigRetryAt
+	cli.mediaConfigMutex.Unlock()
+	if config != nil {
+		return config.UploadSize
+	}
+	if time.Now().Before(retryAt) {
+		return 0
+	}
+
+	// the lock isn't held while fetching, so that a slow homeserver doesn't hold up concurrent uploads
+	config, err := cli.MediaConfigContext(ctx)
+	cli.mediaConfigMutex.Lock()
+	defer cli.mediaConfigMutex.Unlock()
+	if err != nil || config == nil {
+		// let the upload itself fail if it's too large
+		if ctx.Err() == nil {
+			cli.mediaConfigRetryAt = time.Now().Add(mediaConfigRetryInterval)
+		}
+		return 0
+	}
+	cli.mediaConfig = config
+	return config.UploadSize
+}
+
+// uploadReader reports the progress of an upload and stops it once it exceeds the size limit.
+type uploadReader struct {
+	r          io.Reader
+	sent       int64
+	total      int64
+	limit      int64
+	onProgress func(sent, total int64)
+
+	errMutex sync.Mutex // protects err, as the body may still be read after the response has arrived
+	err      error      // set if the upload was stopped for being too large
+}
+
+func (u *uploadReader) Read(p []byte) (int, error) {
+	if err := u.tooLarge(); err != nil {
+		return 0, err
+	}
+	n, err := u.r.Read(p)
+	u.sent += int64(n)
+	if u.limit > 0 && u.sent > u.limit {
+		u.errMutex.Lock()
+		u.err = fmt.Errorf("upload exceeds the homeserver's limit of %d bytes: %w", u.limit, response.ErrTooLarge)
+		u.errMutex.Unlock()
+		return 0, u.tooLarge()
+	}
+	if n > 0 && u.onProgress != nil {
+		u.onProgress(u.sent, u.total)
+	}
+	return n, err
+}
+
+// tooLarge returns the error if the upload was stopped for being too large.
+func (u *uploadReader) tooLarge() error {
+	u.errMutex.Lock()
+	defer u.errMutex.Unlock()
+	return u.err
+}
@@ -0,0 +1,234 @@
+package gomatrix
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rbns/gomatrix/response"
+)
+
+func TestClient_Download(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Path {
+		case "/_matrix/client/v1/media/download/example.org/abc123":
+			header := http.Header{}
+			header.Set("Content-Type", "image/png")
+			header.Set("Content-Disposition", `inline; filename="cat.png"`)
+			return &http.Response{
+				StatusCode:    200,
+				Header:        header,
+				ContentLength: 4,
+				Body:          ioutil.NopCloser(bytes.NewBufferString("\x89PNG")),
+			}, nil
+		case "/_matrix/client/v1/media/download/example.org/missing":
+			return &http.Response{
+				StatusCode: 404,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"errcode":"M_NOT_FOUND","error":"Not found"}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+	cli.AuthenticatedMedia = true
+
+	media, err := cli.Download("mxc://example.org/abc123")
+	if err != nil {
+		t.Fatalf("Download: error, got %s", err.Error())
+	}
+	defer media.Body.Close()
+	body, _ := ioutil.ReadAll(media.Body)
+	if string(body) != "\x89PNG" || media.ContentType != "image/png" || media.Filename != "cat.png" || media.ContentLength != 4 {
+		t.Fatalf("Download: got %+v with body %q", media, body)
+	}
+
+	if _, err = cli.Download("mxc://example.org/missing"); !errors.Is(err, response.ErrNotFound) {
+		t.Fatalf("Download: got error %v, want M_NOT_FOUND", err)
+	}
+	if _, err = cli.Download("https://example.org/cat.png"); err == nil {
+		t.Fatal("Download: expected an error for a non-MXC URI")
+	}
+}
+
+func TestClient_DownloadRetry(t *testing.T) {
+	var attempts int
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/_matrix/media/v3/download/example.org/abc123" {
+			return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+		}
+		attempts++
+		if attempts == 1 {
+			return &http.Response{
+				StatusCode: 429,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"errcode":"M_LIMIT_EXCEEDED","error":"Too many requests","retry_after_ms":1}`)),
+			}, nil
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewBufferString("data")),
+		}, nil
+	})
+	cli.RetryPolicy = &RetryPolicy{MaxRetries: 1, MinBackoff: time.Millisecond}
+
+	media, err := cli.Download("mxc://example.org/abc123")
+	if err != nil {
+		t.Fatalf("Download: error, got %s", err.Error())
+	}
+	defer media.Body.Close()
+	if body, _ := ioutil.ReadAll(media.Body); string(body) != "data" || attempts != 2 {
+		t.Fatalf("Download: got body %q after %d attempts, want data after 2", body, attempts)
+	}
+}
+
+func TestClient_DownloadRefreshOnExpiredToken(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Path {
+		case "/_matrix/client/v3/refresh":
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"access_token":"access2","refresh_token":"refresh2"}`)),
+			}, nil
+		case "/_matrix/client/v1/media/download/example.org/abc123":
+			if req.URL.Query().Get("access_token") != "access2" {
+				return &http.Response{
+					StatusCode: 401,
+					Body:       ioutil.NopCloser(bytes.NewBufferString(`{"errcode":"M_UNKNOWN_TOKEN","error":"Access token has expired","soft_logout":true}`)),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("data")),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+	cli.AuthenticatedMedia = true
+	cli.RefreshToken = "refresh1"
+
+	media, err := cli.Download("mxc://example.org/abc123")
+	if err != nil {
+		t.Fatalf("Download: error, got %s", err.Error())
+	}
+	defer media.Body.Close()
+	if body, _ := ioutil.ReadAll(media.Body); string(body) != "data" {
+		t.Fatalf("Download: got body %q, want data", body)
+	}
+}
+
+func TestClient_MediaConfig(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "GET" && req.URL.Path == "/_matrix/media/v3/config" {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"m.upload.size":52428800}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	resp, err := cli.MediaConfig()
+	if err != nil {
+		t.Fatalf("MediaConfig: error, got %s", err.Error())
+	}
+	if resp.UploadSize != 52428800 {
+		t.Fatalf("MediaConfig: got upload size %d", resp.UploadSize)
+	}
+}
+
+func TestClient_Upload(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == "GET" && req.URL.Path == "/_matrix/media/v3/config":
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"m.upload.size":10}`)),
+			}, nil
+		case req.Method == "PUT" && req.URL.Path == "/_matrix/media/v3/upload/example.org/reserved":
+			if req.URL.Query().Get("filename") != "a b.txt" || req.Header.Get("Content-Type") != "text/plain" {
+				t.Errorf("Upload: got query %s and content type %s", req.URL.RawQuery, req.Header.Get("Content-Type"))
+			}
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				return nil, err
+			}
+			if string(body) != "hello" {
+				t.Errorf("Upload: got body %q", body)
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{}`)),
+			}, nil
+		case req.Method == "POST" && req.URL.Path == "/_matrix/media/v3/upload":
+			_, err := ioutil.ReadAll(req.Body)
+			return nil, err
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	var progress []int64
+	resp, err := cli.Upload(&UploadRequest{
+		Content:       bytes.NewBufferString("hello"),
+		ContentType:   "text/plain",
+		ContentLength: 5,
+		Filename:      "a b.txt",
+		MXC:           MXCURI{ServerName: "example.org", MediaID: "reserved"},
+		OnProgress: func(sent, total int64) {
+			if total != 5 {
+				t.Errorf("OnProgress: got total %d, want 5", total)
+			}
+			progress = append(progress, sent)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Upload: error, got %s", err.Error())
+	}
+	if resp.ContentURI != "mxc://example.org/reserved" {
+		t.Errorf("Upload: got content URI %s", resp.ContentURI)
+	}
+	if len(progress) == 0 || progress[len(progress)-1] != 5 {
+		t.Errorf("Upload: got progress %v", progress)
+	}
+
+	// known size: rejected before sending
+	_, err = cli.Upload(&UploadRequest{Content: bytes.NewBufferString("far too large"), ContentLength: 13})
+	if !errors.Is(err, response.ErrTooLarge) {
+		t.Errorf("Upload: got error %v, want M_TOO_LARGE", err)
+	}
+	// unknown size: stopped while sending
+	_, err = cli.Upload(&UploadRequest{Content: ioutil.NopCloser(bytes.NewBufferString("far too large"))})
+	if !errors.Is(err, response.ErrTooLarge) {
+		t.Errorf("Upload: got error %v, want M_TOO_LARGE", err)
+	}
+}
+
+func TestClient_UploadWithoutMediaConfig(t *testing.T) {
+	var configs int
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == "GET" && req.URL.Path == "/_matrix/media/v3/config":
+			configs++
+			return &http.Response{
+				StatusCode: 404,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"errcode":"M_UNRECOGNIZED","error":"Unrecognized request"}`)),
+			}, nil
+		case req.Method == "POST" && req.URL.Path == "/_matrix/media/v3/upload":
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"content_uri":"mxc://example.org/abc"}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := cli.Upload(&UploadRequest{Content: bytes.NewBufferString("hello"), ContentLength: 5}); err != nil {
+			t.Fatalf("Upload: error, got %s", err.Error())
+		}
+	}
+	if configs != 1 {
+		t.Errorf("Upload: fetched the media config %d times, want 1", configs)
+	}
+}
@@ -0,0 +1,146 @@
+package gomatrix
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// MXCURI is a reference to a file in a homeserver's content repository, written as mxc://<server-name>/<media-id>.
+// See https://spec.matrix.org/latest/client-server-api/#matrix-content-mxc-uris
+//
+// The zero MXCURI is empty, and is encoded to JSON as "".
+type MXCURI struct {
+	ServerName string
+	MediaID    string
+}
+
+// ParseMXC parses and validates an MXC URI, such as the URL of an event.ImageMessage.
+func ParseMXC(mxc string) (MXCURI, error) {
+	if !strings.HasPrefix(mxc, "mxc://") {
+		return MXCURI{}, fmt.Errorf("%q is not an MXC URI", mxc)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(mxc, "mxc://"), "/", 2)
+	if len(parts) != 2 {
+		return MXCURI{}, fmt.Errorf("%q has no media ID", mxc)
+	}
+	m := MXCURI{ServerName: parts[0], MediaID: parts[1]}
+	if !validServerName(m.ServerName) {
+		return MXCURI{}, fmt.Errorf("%q has an invalid server name", mxc)
+	}
+	if !validMediaID(m.MediaID) {
+		return MXCURI{}, fmt.Errorf("%q has an invalid media ID", mxc)
+	}
+	return m, nil
+}
+
+// String returns the MXC URI, or "" if it is empty.
+func (m MXCURI) String() string {
+	if m.IsEmpty() {
+		return ""
+	}
+	return "mxc://" + m.ServerName + "/" + m.MediaID
+}
+
+// IsEmpty returns true if m is the zero MXCURI.
+func (m MXCURI) IsEmpty() bool {
+	return m.ServerName == "" && m.MediaID == ""
+}
+
+// MarshalJSON encodes the MXC URI as a JSON string.
+func (m MXCURI) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON decodes and validates a JSON string. An empty string or null decodes to the empty MXCURI.
+func (m *MXCURI) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == nil || *s == "" {
+		*m = MXCURI{}
+		return nil
+	}
+	parsed, err := ParseMXC(*s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// DownloadURL returns the HTTP URL to download the file from the homeserver of cli. The URL does not contain the
+// access token, so it can be handed to e.g. a browser. If cli uses AuthenticatedMedia, requests to the URL need an
+// "Authorization: Bearer" header.
+func (m MXCURI) DownloadURL(cli *Client) string {
+	return m.httpURL(cli, nil, "download", m.ServerName, m.MediaID)
+}
+
+// ThumbnailURL returns the HTTP URL to download a thumbnail of the file, like DownloadURL. method is ThumbnailCrop
+// or ThumbnailScale.
+func (m MXCURI) ThumbnailURL(cli *Client, width, height int, method string) string {
+	query := url.Values{}
+	query.Set("width", strconv.Itoa(width))
+	query.Set("height", strconv.Itoa(height))
+	if method != "" {
+		query.Set("method", method)
+	}
+	return m.httpURL(cli, query, "thumbnail", m.ServerName, m.MediaID)
+}
+
+func (m MXCURI) httpURL(cli *Client, query url.Values, urlPath ...string) string {
+	// copy the URL. Purposefully ignore error as the input is from a valid URL already
+	hsURL, _ := url.Parse(cli.HomeserverURL.String())
+	hsURL.Path = path.Join(append([]string{hsURL.Path}, cli.mediaPath(urlPath...)...)...)
+	hsURL.RawQuery = query.Encode()
+	return hsURL.String()
+}
+
+// validServerName returns true if s is a server name: a DNS name or IP address, optionally followed by a port.
+// See https://spec.matrix.org/latest/appendices/#server-name
+func validServerName(s string) bool {
+	host := s
+	if i := strings.LastIndexByte(s, ':'); i != -1 && !strings.HasSuffix(s, "]") {
+		host = s[:i]
+		port := s[i+1:]
+		if len(port) == 0 || len(port) > 5 {
+			return false
+		}
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return false
+		}
+	}
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		ip := net.ParseIP(host[1 : len(host)-1])
+		return ip != nil && ip.To4() == nil
+	}
+	if len(host) == 0 || len(host) > 255 {
+		return false
+	}
+	for i := 0; i < len(host); i++ {
+		c := host[i]
+		if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != '-' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// validMediaID returns true if s only contains the characters allowed in media IDs: A-Z, a-z, 0-9, _ and -.
+func validMediaID(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != '-' && c != '_' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package gomatrix
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var mxcTests = []struct {
+	MXC   string
+	Valid bool
+}{
+	{"mxc://example.org/SEsfnsuifSDFSSEF", true},
+	{"mxc://matrix.example.org:8448/abc_123-XYZ", true},
+	{"mxc://127.0.0.1/abc", true},
+	{"mxc://[::1]:8448/abc", true},
+	{"https://example.org/abc", false},
+	{"mxc://example.org", false},
+	{"mxc://example.org/", false},
+	{"mxc:///abc", false},
+	{"mxc://example.org/abc/def", false},
+	{"mxc://example.org/abc?x=1", false},
+	{"mxc://exa mple.org/abc", false},
+	{"mxc://example.org:99999/abc", false},
+	{"mxc://example.org:/abc", false},
+}
+
+func TestParseMXC(t *testing.T) {
+	for _, tc := range mxcTests {
+		m, err := ParseMXC(tc.MXC)
+		if tc.Valid && (err != nil || m.String() != tc.MXC) {
+			t.Errorf("ParseMXC(%s): got %v, %v", tc.MXC, m, err)
+		} else if !tc.Valid && err == nil {
+			t.Errorf("ParseMXC(%s): expected an error, got %v", tc.MXC, m)
+		}
+	}
+}
+
+func TestMXCURI_JSON(t *testing.T) {
+	var content struct {
+		URL    MXCURI `json:"url"`
+		Avatar MXCURI `json:"avatar"`
+	}
+	if err := json.Unmarshal([]byte(`{"url":"mxc://example.org/abc","avatar":""}`), &content); err != nil {
+		t.Fatalf("Unmarshal: error, got %s", err)
+	}
+	if content.URL.ServerName != "example.org" || content.URL.MediaID != "abc" || !content.Avatar.IsEmpty() {
+		t.Fatalf("Unmarshal: got %+v", content)
+	}
+	b, err := json.Marshal(content)
+	if err != nil || string(b) != `{"url":"mxc://example.org/abc","avatar":""}` {
+		t.Fatalf("Marshal: got %s, %v", b, err)
+	}
+	if err := json.Unmarshal([]byte(`{"url":"mxc://example.org/../../etc"}`), &content); err == nil {
+		t.Fatal("Unmarshal: expected an error for an invalid MXC URI")
+	}
+}
+
+func TestMXCURI_DownloadURL(t *testing.T) {
+	cli, _ := NewClient("https://test.gomatrix.org", "@user:test.gomatrix.org", "abcdef")
+	m := MXCURI{ServerName: "example.org", MediaID: "abc"}
+	if got, want := m.DownloadURL(cli), "https://test.gomatrix.org/_matrix/media/v3/download/example.org/abc"; got != want {
+		t.Errorf("DownloadURL: got %s, want %s", got, want)
+	}
+}
+
+func TestMXCURI_ThumbnailURL(t *testing.T) {
+	cli, _ := NewClient("https://test.gomatrix.org", "@user:test.gomatrix.org", "abcdef")
+	m := MXCURI{ServerName: "example.org", MediaID: "abc"}
+	cli.AuthenticatedMedia = true
+	got := m.ThumbnailURL(cli, 96, 64, ThumbnailCrop)
+	if want := "https://test.gomatrix.org/_matrix/client/v1/media/thumbnail/example.org/abc?height=64&method=crop&width=96"; got != want {
+		t.Errorf("ThumbnailURL: got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,137 @@
+// Package pushgateway implements the receiving side of the Matrix Push Gateway API, which homeservers use to send
+// notifications to push gateways.
+//
+// Specification can be found at https://spec.matrix.org/latest/push-gateway-api/
+package pushgateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/rbns/gomatrix/event"
+	"github.com/rbns/gomatrix/response"
+)
+
+// NotifyPath is the path homeservers send notifications to. The URL of pushers which use the gateway must end with it.
+const NotifyPath = "/_matrix/push/v1/notify"
+
+// MaxRequestSize is the largest notification request a Handler accepts, in bytes. Notifications are small, and
+// larger requests are rejected so that whoever can reach the gateway can't make it read without limit.
+const MaxRequestSize = 64 * 1024
+
+// Notification priorities.
+const (
+	PrioHigh = "high"
+	PrioLow  = "low"
+)
+
+// Notification is a single notification sent by a homeserver. Pushers with the event_id_only format only get the
+// event and room IDs, the counts and the devices.
+// See https://spec.matrix.org/latest/push-gateway-api/#post_matrixpushv1notify
+type Notification struct {
+	EventID           string          `json:"event_id,omitempty"`
+	RoomID            string          `json:"room_id,omitempty"`
+	Type              string          `json:"type,omitempty"`
+	Sender            string          `json:"sender,omitempty"`
+	SenderDisplayName string          `json:"sender_display_name,omitempty"`
+	RoomName          string          `json:"room_name,omitempty"`
+	RoomAlias         string          `json:"room_alias,omitempty"`
+	UserIsTarget      bool            `json:"user_is_target,omitempty"` // The user is the target of a m.room.member event
+	Prio              string          `json:"prio,omitempty"`           // PrioHigh or PrioLow
+	Content           json.RawMessage `json:"content,omitempty"`
+	Counts            Counts          `json:"counts"`
+	Devices           []Device        `json:"devices"`
+}
+
+// Counts are the user's unread counts at the time of the notification.
+type Counts struct {
+	Unread      int `json:"unread,omitempty"`
+	MissedCalls int `json:"missed_calls,omitempty"`
+}
+
+// Device is a device the notification should be delivered to, as set up with Client.SetPusher.
+type Device struct {
+	AppID     string                 `json:"app_id"`
+	PushKey   string                 `json:"pushkey"`
+	PushKeyTS int64                  `json:"pushkey_ts,omitempty"` // The unix timestamp in seconds when the push key was last updated
+	Data      map[string]interface{} `json:"data,omitempty"`       // The pusher's data, without the url
+	Tweaks    map[string]interface{} `json:"tweaks,omitempty"`     // The tweaks set by the matching push rule
+}
+
+// Event returns the event the notification is about, with its content decoded like events from /sync. The event has
+// no content if the pusher uses the event_id_only format.
+func (n *Notification) Event() (*event.Event, error) {
+	je := struct {
+		Type    string          `json:"type"`
+		Sender  string          `json:"sender"`
+		ID      string          `json:"event_id"`
+		RoomID  string          `json:"room_id"`
+		Content json.RawMessage `json:"content,omitempty"`
+	}{n.Type, n.Sender, n.EventID, n.RoomID, n.Content}
+	b, err := json.Marshal(je)
+	if err != nil {
+		return nil, err
+	}
+	var e event.Event
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// NotifyFunc delivers a notification. It returns the push keys of the devices which rejected it, e.g. because the app
+// was uninstalled, so that the homeserver removes their pushers. If it returns an error, the homeserver will retry the
+// notification later.
+type NotifyFunc func(ctx context.Context, n *Notification) (rejected []string, err error)
+
+// Handler is an http.Handler for the notify endpoint. It should be served at NotifyPath.
+//
+//	http.Handle(pushgateway.NotifyPath, pushgateway.NewHandler(deliver))
+type Handler struct {
+	notify NotifyFunc
+}
+
+// NewHandler returns a Handler which passes notifications to notify.
+func NewHandler(notify NotifyFunc) *Handler {
+	return &Handler{notify: notify}
+}
+
+// ServeHTTP decodes the notification in the request and passes it to the NotifyFunc.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeJSON(w, http.StatusMethodNotAllowed, response.Error{ErrCode: "M_UNRECOGNIZED", Err: "method not allowed"})
+		return
+	}
+	var req struct {
+		Notification *Notification `json:"notification"`
+	}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestSize)).Decode(&req)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeJSON(w, http.StatusRequestEntityTooLarge, response.Error{ErrCode: response.ErrTooLarge.ErrCode, Err: "notification too large"})
+		return
+	}
+	if err != nil || req.Notification == nil {
+		writeJSON(w, http.StatusBadRequest, response.Error{ErrCode: "M_BAD_JSON", Err: "invalid notification"})
+		return
+	}
+	rejected, err := h.notify(r.Context(), req.Notification)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, response.Error{ErrCode: "M_UNKNOWN", Err: err.Error()})
+		return
+	}
+	if rejected == nil {
+		rejected = []string{}
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Rejected []string `json:"rejected"`
+	}{rejected})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
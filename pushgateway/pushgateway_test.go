@@ -0,0 +1,74 @@
+package pushgateway
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rbns/gomatrix/event"
+)
+
+func TestHandler(t *testing.T) {
+	var got *Notification
+	h := NewHandler(func(ctx context.Context, n *Notification) ([]string, error) {
+		got = n
+		return []string{"stale"}, nil
+	})
+
+	body := `{"notification":{
+		"event_id":"$event:bar","room_id":"!foo:bar","type":"m.room.message","sender":"@alice:bar",
+		"sender_display_name":"Alice","prio":"high","content":{"msgtype":"m.text","body":"hello"},
+		"counts":{"unread":2},
+		"devices":[{"app_id":"com.example.app","pushkey":"fresh","tweaks":{"sound":"default"}},
+		           {"app_id":"com.example.app","pushkey":"stale"}]
+	}}`
+	req := httptest.NewRequest("POST", NotifyPath, bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "{\"rejected\":[\"stale\"]}\n" {
+		t.Fatalf("ServeHTTP: got %d %s", rec.Code, rec.Body.String())
+	}
+	if got == nil || got.Prio != PrioHigh || got.Counts.Unread != 2 || len(got.Devices) != 2 ||
+		got.Devices[0].Tweaks["sound"] != "default" {
+		t.Fatalf("ServeHTTP: got notification %+v", got)
+	}
+
+	e, err := got.Event()
+	if err != nil {
+		t.Fatalf("Event: error, got %s", err)
+	}
+	if msg, ok := e.Content.(event.TextMessage); !ok || msg.Body != "hello" || e.RoomID != "!foo:bar" {
+		t.Fatalf("Event: got %+v", e)
+	}
+}
+
+func TestHandler_BadJSON(t *testing.T) {
+	h := NewHandler(func(ctx context.Context, n *Notification) ([]string, error) {
+		t.Fatal("NotifyFunc called for an invalid request")
+		return nil, nil
+	})
+	req := httptest.NewRequest("POST", NotifyPath, bytes.NewBufferString(`{"devices":[]}`))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("ServeHTTP: got status %d, want 400", rec.Code)
+	}
+}
+
+func TestHandler_TooLarge(t *testing.T) {
+	h := NewHandler(func(ctx context.Context, n *Notification) ([]string, error) {
+		t.Fatal("NotifyFunc called for a request which is too large")
+		return nil, nil
+	})
+	body := `{"notification":{"room_name":"` + strings.Repeat("a", MaxRequestSize) + `"}}`
+	req := httptest.NewRequest("POST", NotifyPath, bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("ServeHTTP: got status %d, want 413", rec.Code)
+	}
+}
@@ -0,0 +1,247 @@
+package gomatrix
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rbns/gomatrix/event"
+)
+
+// PushContext is the information about a room which push rules are evaluated against, besides the event itself.
+type PushContext struct {
+	DisplayName string                 // The display name in the room of the user the push rules belong to
+	MemberCount int                    // The number of joined members of the room
+	PowerLevels *event.RoomPowerLevels // The room's power levels. nil means everyone has power level 0.
+}
+
+// NewPushContext returns the PushContext for the given user from the current state of a room.
+func NewPushContext(room *Room, userID string) *PushContext {
+	pctx := &PushContext{}
+	for stateKey, e := range room.State["m.room.member"] {
+		member, ok := e.Content.(event.RoomMember)
+		if !ok {
+			continue
+		}
+		if member.Membership == "join" {
+			pctx.MemberCount++
+		}
+		if stateKey == userID {
+			pctx.DisplayName = member.Displayname
+		}
+	}
+	if e := room.GetStateEvent("m.room.power_levels", ""); e != nil {
+		if pl, ok := e.Content.(event.RoomPowerLevels); ok {
+			pctx.PowerLevels = &pl
+		}
+	}
+	return pctx
+}
+
+// EvaluatePushRules returns the actions of the first enabled rule in ruleset which matches the event, or nil if none
+// match. See https://spec.matrix.org/latest/client-server-api/#push-rules
+//
+// Homeservers don't notify users about their own events, so callers will usually want to skip those before
+// evaluating the rules.
+//
+//	actions := gomatrix.EvaluatePushRules(&rules.Global, ev, gomatrix.NewPushContext(room, cli.UserID))
+//	if actions.Notify() {
+//		page(ev, actions.Highlight())
+//	}
+func EvaluatePushRules(ruleset *event.PushRuleset, e *event.Event, pctx *PushContext) event.PushActions {
+	if rule := MatchPushRule(ruleset, e, pctx); rule != nil {
+		return rule.Actions
+	}
+	return nil
+}
+
+// MatchPushRule is like EvaluatePushRules, but returns the rule which matched.
+func MatchPushRule(ruleset *event.PushRuleset, e *event.Event, pctx *PushContext) *event.PushRule {
+	fields := pushEventFields(e)
+	kinds := []struct {
+		rules []event.PushRule
+		match func(rule *event.PushRule) bool
+	}{
+		{ruleset.Override, func(rule *event.PushRule) bool { return matchPushConditions(rule.Conditions, e, fields, pctx) }},
+		{ruleset.Content, func(rule *event.PushRule) bool {
+			body, ok := lookupPushField(fields, "content.body")
+			return ok && matchGlob(rule.Pattern, body, true)
+		}},
+		{ruleset.Room, func(rule *event.PushRule) bool { return rule.RuleID == e.RoomID }},
+		{ruleset.Sender, func(rule *event.PushRule) bool { return rule.RuleID == e.Sender }},
+		{ruleset.Underride, func(rule *event.PushRule) bool { return matchPushConditions(rule.Conditions, e, fields, pctx) }},
+	}
+	for _, kind := range kinds {
+		for i := range kind.rules {
+			rule := &kind.rules[i]
+			if rule.Enabled && kind.match(rule) {
+				return rule
+			}
+		}
+	}
+	return nil
+}
+
+// matchPushConditions returns true if all of the conditions match. Unknown kinds of conditions never match.
+func matchPushConditions(conds []event.PushCondition, e *event.Event, fields map[string]interface{}, pctx *PushContext) bool {
+	for _, cond := range conds {
+		if !matchPushCondition(cond, e, fields, pctx) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchPushCondition(cond event.PushCondition, e *event.Event, fields map[string]interface{}, pctx *PushContext) bool {
+	switch cond.Kind {
+	case event.PushCondEventMatch:
+		value, ok := lookupPushField(fields, cond.Key)
+		// as homeservers do, patterns for the message body match whole words rather than the entire body
+		return ok && matchGlob(cond.Pattern, value, cond.Key == "content.body")
+	case event.PushCondContainsDisplayName:
+		body, ok := lookupPushField(fields, "content.body")
+		return ok && pctx.DisplayName != "" && matchWords(regexp.QuoteMeta(pctx.DisplayName), body)
+	case event.PushCondRoomMemberCount:
+		return matchMemberCount(cond.Is, pctx.MemberCount)
+	case event.PushCondSenderNotificationPermission:
+		var pl event.RoomPowerLevels
+		if pctx.PowerLevels != nil {
+			pl = *pctx.PowerLevels
+		}
+		return pl.UserLevel(e.Sender) >= pl.NotificationLevel(cond.Key)
+	case event.PushCondEventPropertyIs:
+		value, ok := lookupPushFieldValue(fields, cond.Key)
+		if !ok {
+			return false
+		}
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return false
+		}
+		// compare the JSON encodings so that e.g. int and float64 values are equal
+		got, err1 := json.Marshal(value)
+		want, err2 := json.Marshal(cond.Value)
+		return err1 == nil && err2 == nil && bytes.Equal(got, want)
+	}
+	return false
+}
+
+// matchMemberCount matches a room_member_count comparison such as ">=2". A plain number means "==".
+func matchMemberCount(is string, count int) bool {
+	op := "=="
+	for _, prefix := range []string{"==", "<=", ">=", "<", ">"} {
+		if strings.HasPrefix(is, prefix) {
+			op = prefix
+			is = is[len(prefix):]
+			break
+		}
+	}
+	n, err := strconv.Atoi(is)
+	if err != nil {
+		return false
+	}
+	switch op {
+	case "<":
+		return count < n
+	case ">":
+		return count > n
+	case "<=":
+		return count <= n
+	case ">=":
+		return count >= n
+	}
+	return count == n
+}
+
+// matchGlob matches a case-insensitive glob pattern, where * matches any number of characters and ? matches a single
+// character, against the whole of value, or against whole words in value if words is true.
+func matchGlob(glob, value string, words bool) bool {
+	var expr strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if words {
+		return matchWords(expr.String(), value)
+	}
+	re, err := regexp.Compile("(?is)^" + expr.String() + "$")
+	return err == nil && re.MatchString(value)
+}
+
+// matchWords returns true if the regular expression matches whole words in value, case-insensitively.
+func matchWords(expr, value string) bool {
+	re, err := regexp.Compile(`(?is)(^|\W)` + expr + `(\W|$)`)
+	return err == nil && re.MatchString(value)
+}
+
+// pushEventFields returns the fields of an event as they appear in its JSON, for looking up condition keys.
+func pushEventFields(e *event.Event) map[string]interface{} {
+	fields := map[string]interface{}{
+		"type":             e.Type,
+		"sender":           e.Sender,
+		"room_id":          e.RoomID,
+		"event_id":         e.ID,
+		"origin_server_ts": e.Timestamp,
+	}
+	if e.StateKey != nil {
+		fields["state_key"] = *e.StateKey
+	}
+	raw := e.RawContent
+	if raw == nil {
+		raw, _ = json.Marshal(e.Content)
+	}
+	var content interface{}
+	if json.Unmarshal(raw, &content) == nil {
+		fields["content"] = content
+	}
+	return fields
+}
+
+// lookupPushField returns the string at the dotted path key, e.g. "content.body".
+func lookupPushField(fields map[string]interface{}, key string) (string, bool) {
+	value, ok := lookupPushFieldValue(fields, key)
+	s, isString := value.(string)
+	return s, ok && isString
+}
+
+// lookupPushFieldValue returns the value at the dotted path key. Dots and backslashes which are part of a field name
+// are escaped with a backslash, e.g. "content.m\.relates_to".
+func lookupPushFieldValue(fields map[string]interface{}, key string) (interface{}, bool) {
+	var value interface{} = fields
+	for _, part := range splitPushKey(key) {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = obj[part]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func splitPushKey(key string) []string {
+	var parts []string
+	var part strings.Builder
+	for i := 0; i < len(key); i++ {
+		switch {
+		case key[i] == '\\' && i+1 < len(key) && (key[i+1] == '.' || key[i+1] == '\\'):
+			i++
+			part.WriteByte(key[i])
+		case key[i] == '.':
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(key[i])
+		}
+	}
+	return append(parts, part.String())
+}
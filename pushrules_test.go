@@ -0,0 +1,102 @@
+package gomatrix
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rbns/gomatrix/event"
+)
+
+var testPushRules = `{"global":{
+	"override":[
+		{"rule_id":".m.rule.master","default":true,"enabled":false,"actions":[]},
+		{"rule_id":".m.rule.suppress_notices","default":true,"enabled":true,"actions":["dont_notify"],
+		 "conditions":[{"kind":"event_match","key":"content.msgtype","pattern":"m.notice"}]},
+		{"rule_id":".m.rule.is_room_mention","default":true,"enabled":true,
+		 "actions":["notify",{"set_tweak":"highlight"}],
+		 "conditions":[{"kind":"event_property_is","key":"content.m\\.mentions.room","value":true},
+		               {"kind":"sender_notification_permission","key":"room"}]}
+	],
+	"content":[
+		{"rule_id":".m.rule.contains_user_name","default":true,"enabled":true,"pattern":"alice",
+		 "actions":["notify",{"set_tweak":"sound","value":"default"},{"set_tweak":"highlight"}]}
+	],
+	"room":[{"rule_id":"!muted:bar","default":false,"enabled":true,"actions":["dont_notify"]}],
+	"underride":[
+		{"rule_id":".m.rule.contains_display_name","default":true,"enabled":true,
+		 "actions":["notify",{"set_tweak":"highlight","value":true}],
+		 "conditions":[{"kind":"contains_display_name"}]},
+		{"rule_id":".m.rule.room_one_to_one","default":true,"enabled":true,
+		 "actions":["notify",{"set_tweak":"highlight","value":false}],
+		 "conditions":[{"kind":"room_member_count","is":"2"},{"kind":"event_match","key":"type","pattern":"m.room.message"}]}
+	]
+}}`
+
+func TestEvaluatePushRules(t *testing.T) {
+	var rules event.PushRules
+	if err := json.Unmarshal([]byte(testPushRules), &rules); err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+	pctx := &PushContext{
+		DisplayName: "Mad Hatter",
+		MemberCount: 3,
+		PowerLevels: &event.RoomPowerLevels{Users: map[string]int{"@admin:bar": 100}},
+	}
+
+	testCases := []struct {
+		name      string
+		roomID    string
+		sender    string
+		content   string
+		members   int
+		rule      string
+		notify    bool
+		highlight bool
+	}{
+		{"notice", "!foo:bar", "@bob:bar", `{"msgtype":"m.notice","body":"alice"}`, 3, ".m.rule.suppress_notices", false, false},
+		{"user name", "!foo:bar", "@bob:bar", `{"msgtype":"m.text","body":"Hi ALICE!"}`, 3, ".m.rule.contains_user_name", true, true},
+		{"user name in a word", "!foo:bar", "@bob:bar", `{"msgtype":"m.text","body":"malice"}`, 3, "", false, false},
+		{"muted room", "!muted:bar", "@bob:bar", `{"msgtype":"m.text","body":"hi"}`, 3, "!muted:bar", false, false},
+		{"display name", "!foo:bar", "@bob:bar", `{"msgtype":"m.text","body":"ask the mad hatter."}`, 3, ".m.rule.contains_display_name", true, true},
+		{"room mention", "!foo:bar", "@admin:bar", `{"msgtype":"m.text","body":"hi","m.mentions":{"room":true}}`, 3, ".m.rule.is_room_mention", true, true},
+		{"room mention without permission", "!foo:bar", "@bob:bar", `{"msgtype":"m.text","body":"hi","m.mentions":{"room":true}}`, 3, "", false, false},
+		{"one to one", "!foo:bar", "@bob:bar", `{"msgtype":"m.text","body":"hi"}`, 2, ".m.rule.room_one_to_one", true, false},
+	}
+	for _, tc := range testCases {
+		var e event.Event
+		if err := json.Unmarshal([]byte(`{"type":"m.room.message","sender":"`+tc.sender+`","room_id":"`+tc.roomID+`","content":`+tc.content+`}`), &e); err != nil {
+			t.Fatalf("%s: Unmarshal: %s", tc.name, err)
+		}
+		pctx.MemberCount = tc.members
+		rule := MatchPushRule(&rules.Global, &e, pctx)
+		if rule == nil {
+			if tc.rule != "" {
+				t.Errorf("%s: no rule matched, want %s", tc.name, tc.rule)
+			}
+			continue
+		}
+		if rule.RuleID != tc.rule {
+			t.Errorf("%s: got rule %s, want %s", tc.name, rule.RuleID, tc.rule)
+		}
+		if rule.Actions.Notify() != tc.notify || rule.Actions.Highlight() != tc.highlight {
+			t.Errorf("%s: got notify=%t highlight=%t, want notify=%t highlight=%t", tc.name,
+				rule.Actions.Notify(), rule.Actions.Highlight(), tc.notify, tc.highlight)
+		}
+	}
+}
+
+func TestPushAction_MarshalJSON(t *testing.T) {
+	actions := event.PushActions{
+		{Action: event.PushActionNotify},
+		{Action: event.PushActionSetTweak, Tweak: event.PushTweakSound, Value: "default"},
+		{Action: event.PushActionSetTweak, Tweak: event.PushTweakHighlight},
+	}
+	b, err := json.Marshal(actions)
+	if err != nil {
+		t.Fatalf("Marshal: %s", err)
+	}
+	want := `["notify",{"set_tweak":"sound","value":"default"},{"set_tweak":"highlight"}]`
+	if string(b) != want {
+		t.Fatalf("Marshal: got %s, want %s", b, want)
+	}
+}
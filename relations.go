@@ -0,0 +1,184 @@
+package gomatrix
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rbns/gomatrix/event"
+	"github.com/rbns/gomatrix/response"
+)
+
+// SendReply sends an m.text message replying to the given event, with the fallback body and HTML for clients which
+// don't support replies. If the event is part of a thread, the reply is sent to the thread as well.
+// See https://spec.matrix.org/latest/client-server-api/#rich-replies
+func (cli *Client) SendReply(roomID string, inReplyTo *event.Event, text string) (*response.SendEvent, error) {
+	return cli.SendReplyContext(context.Background(), roomID, inReplyTo, text)
+}
+
+// SendReplyContext is like SendReply, but the request is bound to ctx.
+func (cli *Client) SendReplyContext(ctx context.Context, roomID string, inReplyTo *event.Event, text string) (*response.SendEvent, error) {
+	relatesTo := &event.RelatesTo{InReplyTo: &event.InReplyTo{EventID: inReplyTo.ID}}
+	if rel := inReplyTo.RelatesTo(); rel != nil && rel.RelType == event.RelTypeThread {
+		relatesTo.RelType = event.RelTypeThread
+		relatesTo.EventID = rel.EventID
+	}
+	content, err := replyMessage(roomID, inReplyTo, text, relatesTo)
+	if err != nil {
+		return nil, err
+	}
+	return cli.SendMessageEventContext(ctx, roomID, "m.room.message", content)
+}
+
+// SendThreadReply sends an m.text message to the thread of the given event, starting a thread from it if it isn't
+// part of one already. See https://spec.matrix.org/latest/client-server-api/#threading
+func (cli *Client) SendThreadReply(roomID string, inThreadOf *event.Event, text string) (*response.SendEvent, error) {
+	return cli.SendThreadReplyContext(context.Background(), roomID, inThreadOf, text)
+}
+
+// SendThreadReplyContext is like SendThreadReply, but the request is bound to ctx.
+func (cli *Client) SendThreadReplyContext(ctx context.Context, roomID string, inThreadOf *event.Event, text string) (*response.SendEvent, error) {
+	rootID := inThreadOf.ID
+	if rel := inThreadOf.RelatesTo(); rel != nil && rel.RelType == event.RelTypeThread {
+		rootID = rel.EventID
+	}
+	return cli.SendMessageEventContext(ctx, roomID, "m.room.message", event.TextMessage{
+		MsgType: "m.text",
+		Body:    text,
+		RelatesTo: &event.RelatesTo{
+			RelType: event.RelTypeThread,
+			EventID: rootID,
+			// clients without thread support show the message as a reply to the previous one
+			InReplyTo:     &event.InReplyTo{EventID: inThreadOf.ID},
+			IsFallingBack: true,
+		},
+	})
+}
+
+// SendEdit replaces the text of one of the user's m.text messages. Clients which don't support edits show the new
+// text prefixed by "* ". See https://spec.matrix.org/latest/client-server-api/#event-replacements
+func (cli *Client) SendEdit(roomID, eventID, text string) (*response.SendEvent, error) {
+	return cli.SendEditContext(context.Background(), roomID, eventID, text)
+}
+
+// SendEditContext is like SendEdit, but the request is bound to ctx.
+func (cli *Client) SendEditContext(ctx context.Context, roomID, eventID, text string) (*response.SendEvent, error) {
+	return cli.SendMessageEventContext(ctx, roomID, "m.room.message", event.TextMessage{
+		MsgType:    "m.text",
+		Body:       "* " + text,
+		NewContent: &event.TextMessage{Body: text},
+		RelatesTo:  &event.RelatesTo{RelType: event.RelTypeReplace, EventID: eventID},
+	})
+}
+
+// SendReaction reacts to an event with key, which is usually an emoji.
+// See https://spec.matrix.org/latest/client-server-api/#event-annotations-and-reactions
+func (cli *Client) SendReaction(roomID, eventID, key string) (*response.SendEvent, error) {
+	return cli.SendReactionContext(context.Background(), roomID, eventID, key)
+}
+
+// SendReactionContext is like SendReaction, but the request is bound to ctx.
+func (cli *Client) SendReactionContext(ctx context.Context, roomID, eventID, key string) (*response.SendEvent, error) {
+	return cli.SendMessageEventContext(ctx, roomID, "m.reaction", event.Reaction{
+		RelatesTo: event.RelatesTo{RelType: event.RelTypeAnnotation, EventID: eventID, Key: key},
+	})
+}
+
+// Relations returns a page of the events which relate to the given event. relType and eventType restrict the
+// relations to those of one type, e.g. event.RelTypeThread, and of one event type; both may be empty. from is the
+// next_batch of the previous page, or "" for the first page. If limit is 0 the server default is used.
+// See https://spec.matrix.org/latest/client-server-api/#get_matrixclientv1roomsroomidrelationseventid
+func (cli *Client) Relations(roomID, eventID, relType, eventType, from string, limit int) (*response.Relations, error) {
+	return cli.RelationsContext(context.Background(), roomID, eventID, relType, eventType, from, limit)
+}
+
+// RelationsContext is like Relations, but the request is bound to ctx.
+func (cli *Client) RelationsContext(ctx context.Context, roomID, eventID, relType, eventType, from string, limit int) (resp *response.Relations, err error) {
+	urlPath := []string{"_matrix", "client", "v1", "rooms", roomID, "relations", eventID}
+	if relType != "" {
+		urlPath = append(urlPath, relType)
+		if eventType != "" {
+			urlPath = append(urlPath, eventType)
+		}
+	} else if eventType != "" {
+		return nil, fmt.Errorf("relations can only be filtered by event type along with a relation type")
+	}
+	u, _ := url.Parse(cli.BuildBaseURL(urlPath...))
+	q := u.Query()
+	if from != "" {
+		q.Set("from", from)
+	}
+	if limit != 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	u.RawQuery = q.Encode()
+	_, err = cli.MakeRequestContext(ctx, "GET", u.String(), nil, &resp)
+	return
+}
+
+var replyFallbackRegex = regexp.MustCompile(`(?s)^<mx-reply>.*</mx-reply>`)
+
+// replyMessage builds a reply to inReplyTo with the fallback body and HTML. The content of inReplyTo is taken from
+// RawContent if it was decoded from JSON, and from Content otherwise.
+func replyMessage(roomID string, inReplyTo *event.Event, text string, relatesTo *event.RelatesTo) (event.TextMessage, error) {
+	raw := inReplyTo.RawContent
+	if raw == nil {
+		if inReplyTo.Content == nil {
+			return event.TextMessage{}, fmt.Errorf("event %s has no content to reply to", inReplyTo.ID)
+		}
+		var err error
+		if raw, err = json.Marshal(inReplyTo.Content); err != nil {
+			return event.TextMessage{}, err
+		}
+	}
+	var orig struct {
+		Body          string `json:"body"`
+		Format        string `json:"format"`
+		FormattedBody string `json:"formatted_body"`
+	}
+	if err := json.Unmarshal(raw, &orig); err != nil {
+		return event.TextMessage{}, fmt.Errorf("failed to decode the content of %s: %w", inReplyTo.ID, err)
+	}
+
+	// strip the fallback of the message replied to, if it is a reply itself
+	origBody := orig.Body
+	if strings.HasPrefix(origBody, "> ") {
+		lines := strings.Split(origBody, "\n")
+		for len(lines) > 0 && strings.HasPrefix(lines[0], ">") {
+			lines = lines[1:]
+		}
+		origBody = strings.TrimPrefix(strings.Join(lines, "\n"), "\n")
+	}
+	origHTML := strings.Replace(html.EscapeString(origBody), "\n", "<br />", -1)
+	if orig.Format == "org.matrix.custom.html" {
+		origHTML = replyFallbackRegex.ReplaceAllString(orig.FormattedBody, "")
+	}
+
+	var body strings.Builder
+	for i, line := range strings.Split(origBody, "\n") {
+		if i == 0 {
+			fmt.Fprintf(&body, "> <%s> %s\n", inReplyTo.Sender, line)
+		} else {
+			fmt.Fprintf(&body, "> %s\n", line)
+		}
+	}
+	body.WriteString("\n" + text)
+
+	formatted := fmt.Sprintf(`<mx-reply><blockquote><a href="https://matrix.to/#/%s/%s">In reply to</a> `+
+		`<a href="https://matrix.to/#/%s">%s</a><br />%s</blockquote></mx-reply>%s`,
+		url.PathEscape(roomID), url.PathEscape(inReplyTo.ID), url.PathEscape(inReplyTo.Sender),
+		html.EscapeString(inReplyTo.Sender), origHTML, strings.Replace(html.EscapeString(text), "\n", "<br />", -1))
+
+	return event.TextMessage{
+		MsgType:       "m.text",
+		Body:          body.String(),
+		Format:        "org.matrix.custom.html",
+		FormattedBody: formatted,
+		RelatesTo:     relatesTo,
+	}, nil
+}
@@ -0,0 +1,178 @@
+package gomatrix
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rbns/gomatrix/event"
+)
+
+func TestClient_SendReply(t *testing.T) {
+	var sent map[string]interface{}
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "PUT" && strings.HasPrefix(req.URL.Path, "/_matrix/client/r0/rooms/!foo:bar/send/m.room.message/") {
+			body, _ := ioutil.ReadAll(req.Body)
+			if err := json.Unmarshal(body, &sent); err != nil {
+				t.Fatalf("SendReply: invalid body %s", body)
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"event_id":"$reply:bar"}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	var orig event.Event
+	if err := json.Unmarshal([]byte(`{"type":"m.room.message","event_id":"$orig:bar","sender":"@alice:bar","content":{
+		"msgtype":"m.text","body":"> <@bob:bar> first\n\nsecond\nthird",
+		"m.relates_to":{"rel_type":"m.thread","event_id":"$root:bar","m.in_reply_to":{"event_id":"$first:bar"}}}}`), &orig); err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+	if _, err := cli.SendReply("!foo:bar", &orig, "a <reply>"); err != nil {
+		t.Fatalf("SendReply: error, got %s", err.Error())
+	}
+
+	if sent["body"] != "> <@alice:bar> second\n> third\n\na <reply>" {
+		t.Errorf("SendReply: got body %q", sent["body"])
+	}
+	wantHTML := `<mx-reply><blockquote><a href="https://matrix.to/#/%21foo:bar/$orig:bar">In reply to</a> ` +
+		`<a href="https://matrix.to/#/@alice:bar">@alice:bar</a><br />second<br />third</blockquote></mx-reply>a &lt;reply&gt;`
+	if sent["formatted_body"] != wantHTML {
+		t.Errorf("SendReply: got formatted_body %q", sent["formatted_body"])
+	}
+	relatesTo, _ := sent["m.relates_to"].(map[string]interface{})
+	inReplyTo, _ := relatesTo["m.in_reply_to"].(map[string]interface{})
+	if relatesTo["rel_type"] != "m.thread" || relatesTo["event_id"] != "$root:bar" || inReplyTo["event_id"] != "$orig:bar" {
+		t.Errorf("SendReply: got m.relates_to %v", sent["m.relates_to"])
+	}
+}
+
+func TestClient_SendReplyToBuiltEvent(t *testing.T) {
+	var sent map[string]interface{}
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		body, _ := ioutil.ReadAll(req.Body)
+		if err := json.Unmarshal(body, &sent); err != nil {
+			t.Fatalf("SendReply: invalid body %s", body)
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{"event_id":"$reply:bar"}`)),
+		}, nil
+	})
+
+	orig := &event.Event{ID: "$orig:bar", Sender: "@alice:bar", Type: "m.room.message", Content: event.TextMessage{
+		MsgType:   "m.text",
+		Body:      "hello",
+		RelatesTo: &event.RelatesTo{RelType: event.RelTypeThread, EventID: "$root:bar"},
+	}}
+	if _, err := cli.SendReply("!foo:bar", orig, "hi"); err != nil {
+		t.Fatalf("SendReply: error, got %s", err.Error())
+	}
+	if sent["body"] != "> <@alice:bar> hello\n\nhi" {
+		t.Errorf("SendReply: got body %q", sent["body"])
+	}
+	if relatesTo, _ := sent["m.relates_to"].(map[string]interface{}); relatesTo["event_id"] != "$root:bar" {
+		t.Errorf("SendReply: got m.relates_to %v, want the thread to be kept", sent["m.relates_to"])
+	}
+
+	invalid := &event.Event{ID: "$invalid:bar", RawContent: json.RawMessage(`["not an object"]`)}
+	if _, err := cli.SendReply("!foo:bar", invalid, "hi"); err == nil {
+		t.Error("SendReply: got no error for an event with invalid content")
+	}
+}
+
+func TestClient_SendEdit(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "PUT" && strings.HasPrefix(req.URL.Path, "/_matrix/client/r0/rooms/!foo:bar/send/m.room.message/") {
+			var e event.Event
+			body, _ := ioutil.ReadAll(req.Body)
+			if err := json.Unmarshal([]byte(`{"type":"m.room.message","content":`+string(body)+`}`), &e); err != nil {
+				t.Fatalf("SendEdit: invalid body %s", body)
+			}
+			msg, _ := e.Content.(event.TextMessage)
+			if msg.Body != "* fixed" || msg.NewContent == nil || msg.NewContent.Body != "fixed" || msg.NewContent.MsgType != "m.text" {
+				t.Errorf("SendEdit: got body %s", body)
+			}
+			if rel := e.RelatesTo(); rel == nil || rel.RelType != event.RelTypeReplace || rel.EventID != "$typo:bar" {
+				t.Errorf("SendEdit: got m.relates_to %+v", rel)
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"event_id":"$edit:bar"}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	if _, err := cli.SendEdit("!foo:bar", "$typo:bar", "fixed"); err != nil {
+		t.Fatalf("SendEdit: error, got %s", err.Error())
+	}
+}
+
+func TestClient_Relations(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "GET" && req.URL.Path == "/_matrix/client/v1/rooms/!foo:bar/relations/$root:bar/m.thread/m.room.message" {
+			if req.URL.Query().Get("from") != "page2" || req.URL.Query().Get("access_token") != "abcdef" {
+				t.Errorf("Relations: got query %s", req.URL.RawQuery)
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body: ioutil.NopCloser(bytes.NewBufferString(`{"chunk":[{"type":"m.room.message","event_id":"$reply:bar",
+					"content":{"msgtype":"m.text","body":"hi","m.relates_to":{"rel_type":"m.thread","event_id":"$root:bar"}}}],
+					"next_batch":"page3"}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	resp, err := cli.Relations("!foo:bar", "$root:bar", event.RelTypeThread, "m.room.message", "page2", 0)
+	if err != nil {
+		t.Fatalf("Relations: error, got %s", err.Error())
+	}
+	if len(resp.Chunk) != 1 || resp.NextBatch != "page3" || resp.Chunk[0].RelatesTo().EventID != "$root:bar" {
+		t.Fatalf("Relations: got %+v", resp)
+	}
+}
+
+func TestMessage_MarshalJSONPointer(t *testing.T) {
+	relatesTo := &event.RelatesTo{InReplyTo: &event.InReplyTo{EventID: "$ev:bar"}}
+	for _, tc := range []struct {
+		content interface{}
+		msgtype string
+	}{
+		{&event.TextMessage{Body: "hi", RelatesTo: relatesTo}, "m.text"},
+		{&event.EmoteMessage{TextMessage: event.TextMessage{Body: "waves", RelatesTo: relatesTo}}, "m.emote"},
+		{&event.NoticeMessage{Body: "hi", RelatesTo: relatesTo}, "m.notice"},
+		{&event.VideoMessage{Body: "video.mp4"}, "m.video"},
+		{&event.ImageMessage{Body: "image.png"}, "m.image"},
+		{&event.FileMessage{Body: "file.txt"}, "m.file"},
+		{&event.LocationMessage{Body: "home"}, "m.location"},
+		{&event.AudioMessage{Body: "audio.ogg"}, "m.audio"},
+	} {
+		b, err := json.Marshal(tc.content)
+		if err != nil {
+			t.Fatalf("Marshal(%T): error, got %s", tc.content, err)
+		}
+		var got struct {
+			MsgType   string           `json:"msgtype"`
+			RelatesTo *event.RelatesTo `json:"m.relates_to"`
+		}
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatalf("Unmarshal(%T): error, got %s", tc.content, err)
+		}
+		if got.MsgType != tc.msgtype {
+			t.Errorf("Marshal(%T): got msgtype %q, want %q", tc.content, got.MsgType, tc.msgtype)
+		}
+		if tc.msgtype == "m.text" || tc.msgtype == "m.emote" || tc.msgtype == "m.notice" {
+			if got.RelatesTo == nil || got.RelatesTo.InReplyTo.EventID != "$ev:bar" {
+				t.Errorf("Marshal(%T): got %s, want m.relates_to", tc.content, b)
+			}
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package request
 
-import "github.com/rbns/gomatrix/event"
+import (
+	"encoding/json"
+
+	"github.com/rbns/gomatrix/event"
+)
 
 // Register is the JSON request for http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-register
 type Register struct {
@@ -10,18 +14,99 @@ type Register struct {
 	DeviceID                 string      `json:"device_id,omitempty"`
 	InitialDeviceDisplayName string      `json:"initial_device_display_name"`
 	Auth                     interface{} `json:"auth,omitempty"`
+	RefreshToken             bool        `json:"refresh_token,omitempty"` // Ask for a refresh token and an expiring access token.
 }
 
 // Login is the JSON request for http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-login
 type Login struct {
-	Type                     string `json:"type"`
-	Password                 string `json:"password,omitempty"`
-	Medium                   string `json:"medium,omitempty"`
-	User                     string `json:"user,omitempty"`
+	Type       string          `json:"type"`
+	Identifier *UserIdentifier `json:"identifier,omitempty"`
+	Password   string          `json:"password,omitempty"`
+	// Deprecated: use Identifier with a Type of IdentifierTypeThirdParty instead.
+	Medium string `json:"medium,omitempty"`
+	// Deprecated: use Identifier with a Type of IdentifierTypeUser instead.
+	User string `json:"user,omitempty"`
+	// Deprecated: use Identifier with a Type of IdentifierTypeThirdParty instead.
 	Address                  string `json:"address,omitempty"`
 	Token                    string `json:"token,omitempty"`
 	DeviceID                 string `json:"device_id,omitempty"`
 	InitialDeviceDisplayName string `json:"initial_device_display_name,omitempty"`
+	RefreshToken             bool   `json:"refresh_token,omitempty"` // Ask for a refresh token and an expiring access token.
+}
+
+// Refresh is the JSON request for https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3refresh
+type Refresh struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Login and user-interactive authentication types.
+// See https://spec.matrix.org/latest/client-server-api/#authentication-types
+const (
+	AuthTypePassword      = "m.login.password"
+	AuthTypeToken         = "m.login.token"
+	AuthTypeSSO           = "m.login.sso"
+	AuthTypeRecaptcha     = "m.login.recaptcha"
+	AuthTypeEmailIdentity = "m.login.email.identity"
+	AuthTypeMsisdn        = "m.login.msisdn"
+	AuthTypeTerms         = "m.login.terms"
+	AuthTypeDummy         = "m.login.dummy"
+)
+
+// AuthData is the "auth" JSON object sent to endpoints which use user-interactive authentication.
+// See https://spec.matrix.org/latest/client-server-api/#user-interactive-authentication-api
+//
+// Only the fields relevant to the stage being completed need to be set.
+type AuthData struct {
+	Type          string               `json:"type,omitempty"`
+	Session       string               `json:"session,omitempty"`
+	Identifier    *UserIdentifier      `json:"identifier,omitempty"`     // m.login.password
+	Password      string               `json:"password,omitempty"`       // m.login.password
+	Response      string               `json:"response,omitempty"`       // m.login.recaptcha
+	ThreePIDCreds *ThreePIDCredentials `json:"threepid_creds,omitempty"` // m.login.email.identity and m.login.msisdn
+	Token         string               `json:"token,omitempty"`          // m.login.token
+}
+
+// ThreePIDCredentials proves ownership of a 3PID which has been validated with a token. It is a JSON object used in
+// https://spec.matrix.org/latest/client-server-api/#email-based-identity--homeserver
+type ThreePIDCredentials struct {
+	SID           string `json:"sid"`
+	ClientSecret  string `json:"client_secret"`
+	IDServer      string `json:"id_server,omitempty"`
+	IDAccessToken string `json:"id_access_token,omitempty"`
+}
+
+// Identifier types for UserIdentifier. See https://spec.matrix.org/latest/client-server-api/#identifier-types
+const (
+	IdentifierTypeUser       = "m.id.user"
+	IdentifierTypeThirdParty = "m.id.thirdparty"
+	IdentifierTypePhone      = "m.id.phone"
+)
+
+// UserIdentifier identifies the user logging in. It is a JSON object used in
+// https://spec.matrix.org/latest/client-server-api/#identifier-types
+type UserIdentifier struct {
+	Type    string `json:"type"`
+	User    string `json:"user,omitempty"`    // The user ID or localpart, for m.id.user
+	Medium  string `json:"medium,omitempty"`  // The 3PID medium, e.g. "email", for m.id.thirdparty
+	Address string `json:"address,omitempty"` // The 3PID address, for m.id.thirdparty
+	Country string `json:"country,omitempty"` // The two-letter country code, for m.id.phone
+	Phone   string `json:"phone,omitempty"`   // The phone number, for m.id.phone
+}
+
+// NewUserIdentifier returns an m.id.user identifier for the given user ID or localpart.
+func NewUserIdentifier(user string) *UserIdentifier {
+	return &UserIdentifier{Type: IdentifierTypeUser, User: user}
+}
+
+// NewThirdPartyIdentifier returns an m.id.thirdparty identifier for the given 3PID, e.g. ("email", "alice@example.org").
+func NewThirdPartyIdentifier(medium, address string) *UserIdentifier {
+	return &UserIdentifier{Type: IdentifierTypeThirdParty, Medium: medium, Address: address}
+}
+
+// NewPhoneIdentifier returns an m.id.phone identifier for the given phone number, which will be interpreted as
+// if dialled from the given country.
+func NewPhoneIdentifier(country, phone string) *UserIdentifier {
+	return &UserIdentifier{Type: IdentifierTypePhone, Country: country, Phone: phone}
 }
 
 // CreateRoom is the JSON request for https://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-createroom
@@ -31,7 +116,7 @@ type CreateRoom struct {
 	Name            string                 `json:"name,omitempty"`
 	Topic           string                 `json:"topic,omitempty"`
 	Invite          []string               `json:"invite,omitempty"`
-	Invite3PID      []Invite3PID        `json:"invite_3pid,omitempty"`
+	Invite3PID      []Invite3PID           `json:"invite_3pid,omitempty"`
 	CreationContent map[string]interface{} `json:"creation_content,omitempty"`
 	InitialState    []event.Event          `json:"initial_state,omitempty"`
 	Preset          string                 `json:"preset,omitempty"`
@@ -78,3 +163,213 @@ type Typing struct {
 	Typing  bool  `json:"typing"`
 	Timeout int64 `json:"timeout"`
 }
+
+// UpdateDevice is the JSON request for https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3devicesdeviceid
+type UpdateDevice struct {
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+// DeleteDevice is the JSON request for https://spec.matrix.org/latest/client-server-api/#delete_matrixclientv3devicesdeviceid
+type DeleteDevice struct {
+	Auth *AuthData `json:"auth,omitempty"`
+}
+
+// DeleteDevices is the JSON request for https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3delete_devices
+type DeleteDevices struct {
+	Devices []string  `json:"devices"`
+	Auth    *AuthData `json:"auth,omitempty"`
+}
+
+// ChangePassword is the JSON request for https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3accountpassword
+type ChangePassword struct {
+	NewPassword   string    `json:"new_password"`
+	LogoutDevices *bool     `json:"logout_devices,omitempty"` // The homeserver logs out other devices if this is unset.
+	Auth          *AuthData `json:"auth,omitempty"`
+}
+
+// DeactivateAccount is the JSON request for https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3accountdeactivate
+type DeactivateAccount struct {
+	Erase    bool      `json:"erase,omitempty"`
+	IDServer string    `json:"id_server,omitempty"`
+	Auth     *AuthData `json:"auth,omitempty"`
+}
+
+// EmailRequestToken is the JSON request for https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3account3pidemailrequesttoken
+type EmailRequestToken struct {
+	ClientSecret  string `json:"client_secret"`
+	Email         string `json:"email"`
+	SendAttempt   int    `json:"send_attempt"`
+	NextLink      string `json:"next_link,omitempty"`
+	IDServer      string `json:"id_server,omitempty"`
+	IDAccessToken string `json:"id_access_token,omitempty"`
+}
+
+// MsisdnRequestToken is the JSON request for https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3account3pidmsisdnrequesttoken
+type MsisdnRequestToken struct {
+	ClientSecret  string `json:"client_secret"`
+	Country       string `json:"country"`
+	PhoneNumber   string `json:"phone_number"`
+	SendAttempt   int    `json:"send_attempt"`
+	NextLink      string `json:"next_link,omitempty"`
+	IDServer      string `json:"id_server,omitempty"`
+	IDAccessToken string `json:"id_access_token,omitempty"`
+}
+
+// AddThreePID is the JSON request for https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3account3pidadd
+type AddThreePID struct {
+	ClientSecret string    `json:"client_secret"`
+	SID          string    `json:"sid"`
+	Auth         *AuthData `json:"auth,omitempty"`
+}
+
+// BindThreePID is the JSON request for https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3account3pidbind
+type BindThreePID struct {
+	ClientSecret  string `json:"client_secret"`
+	SID           string `json:"sid"`
+	IDServer      string `json:"id_server"`
+	IDAccessToken string `json:"id_access_token"`
+}
+
+// ThreePID is the JSON request for https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3account3pidunbind
+// and https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3account3piddelete
+type ThreePID struct {
+	Medium   string `json:"medium"`
+	Address  string `json:"address"`
+	IDServer string `json:"id_server,omitempty"`
+}
+
+// Presence is the JSON request for https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3presenceuseridstatus
+type Presence struct {
+	Presence  string `json:"presence"`
+	StatusMsg string `json:"status_msg,omitempty"`
+}
+
+// PublicRooms is the JSON request for https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3publicrooms
+type PublicRooms struct {
+	Limit                int                `json:"limit,omitempty"`
+	Since                string             `json:"since,omitempty"`
+	Filter               *PublicRoomsFilter `json:"filter,omitempty"`
+	IncludeAllNetworks   bool               `json:"include_all_networks,omitempty"`
+	ThirdPartyInstanceID string             `json:"third_party_instance_id,omitempty"`
+}
+
+// PublicRoomsFilter is a JSON object used in https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3publicrooms
+type PublicRoomsFilter struct {
+	GenericSearchTerm string   `json:"generic_search_term,omitempty"`
+	RoomTypes         []string `json:"room_types,omitempty"`
+}
+
+// RoomVisibility is the JSON request for https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3directorylistroomroomid
+type RoomVisibility struct {
+	Visibility string `json:"visibility"`
+}
+
+// CreateAlias is the JSON request for https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3directoryroomroomalias
+type CreateAlias struct {
+	RoomID string `json:"room_id"`
+}
+
+// Receipt is the JSON request for https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3roomsroomidreceiptreceipttypeeventid
+type Receipt struct {
+	ThreadID string `json:"thread_id,omitempty"`
+}
+
+// ReadMarkers is the JSON request for https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3roomsroomidread_markers
+type ReadMarkers struct {
+	FullyRead   string `json:"m.fully_read,omitempty"`
+	Read        string `json:"m.read,omitempty"`
+	ReadPrivate string `json:"m.read.private,omitempty"`
+}
+
+// PushRule is the JSON request for https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3pushrulesscopekindruleid
+type PushRule struct {
+	Actions    event.PushActions     `json:"actions"`
+	Conditions []event.PushCondition `json:"conditions,omitempty"` // For override and underride rules
+	Pattern    string                `json:"pattern,omitempty"`    // For content rules
+	// The rule ID of a rule of the same kind which the new rule should come before or after. These are sent as
+	// query parameters.
+	Before string `json:"-"`
+	After  string `json:"-"`
+}
+
+// PushRuleEnabled is the JSON request for https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3pushrulesscopekindruleidenabled
+type PushRuleEnabled struct {
+	Enabled bool `json:"enabled"`
+}
+
+// PushRuleActions is the JSON request for https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3pushrulesscopekindruleidactions
+type PushRuleActions struct {
+	Actions event.PushActions `json:"actions"`
+}
+
+// Pusher is the JSON request for https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3pushersset
+type Pusher struct {
+	PushKey           string     `json:"pushkey"`
+	Kind              string     `json:"kind"` // PusherKindHTTP or PusherKindEmail
+	AppID             string     `json:"app_id"`
+	AppDisplayName    string     `json:"app_display_name"`
+	DeviceDisplayName string     `json:"device_display_name"`
+	ProfileTag        string     `json:"profile_tag,omitempty"`
+	Lang              string     `json:"lang"`
+	Data              PusherData `json:"data"`
+	Append            bool       `json:"append,omitempty"` // Keep other pushers with the same push key for other users
+}
+
+// PusherData is the information for the homeserver to push with. URL is required for HTTP pushers.
+type PusherData struct {
+	URL    string `json:"url,omitempty"`    // The push gateway's notify URL, ending in /_matrix/push/v1/notify
+	Format string `json:"format,omitempty"` // PusherFormatEventIDOnly, or "" to send the full event
+}
+
+// Pusher kinds and formats.
+const (
+	PusherKindHTTP          = "http"
+	PusherKindEmail         = "email"
+	PusherFormatEventIDOnly = "event_id_only"
+)
+
+// Search is the JSON request for https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3search
+type Search struct {
+	SearchCategories SearchCategories `json:"search_categories"`
+}
+
+// SearchCategories are the categories to search in. Room events are the only category.
+type SearchCategories struct {
+	RoomEvents *SearchRoomEvents `json:"room_events,omitempty"`
+}
+
+// SearchRoomEvents is the criteria for searching room events.
+type SearchRoomEvents struct {
+	SearchTerm   string              `json:"search_term"`
+	Keys         []string            `json:"keys,omitempty"`     // The fields to search, e.g. "content.body". Defaults to all of them.
+	Filter       json.RawMessage     `json:"filter,omitempty"`   // A RoomEventFilter, e.g. to restrict the search to some rooms
+	OrderBy      string              `json:"order_by,omitempty"` // SearchOrderRank or SearchOrderRecent
+	EventContext *SearchEventContext `json:"event_context,omitempty"`
+	IncludeState bool                `json:"include_state,omitempty"` // Return the current state of the rooms with results
+	Groupings    *SearchGroupings    `json:"groupings,omitempty"`
+}
+
+// SearchEventContext asks for the events around each result.
+type SearchEventContext struct {
+	BeforeLimit    int  `json:"before_limit"`
+	AfterLimit     int  `json:"after_limit"`
+	IncludeProfile bool `json:"include_profile,omitempty"` // Return the sender's profile as it was at the time of each result
+}
+
+// SearchGroupings asks for the results to be grouped, e.g. by room.
+type SearchGroupings struct {
+	GroupBy []SearchGroup `json:"group_by"`
+}
+
+// SearchGroup is a key to group results by: SearchGroupRoomID or SearchGroupSender.
+type SearchGroup struct {
+	Key string `json:"key"`
+}
+
+// Search orderings and groupings.
+const (
+	SearchOrderRank   = "rank"
+	SearchOrderRecent = "recent"
+	SearchGroupRoomID = "room_id"
+	SearchGroupSender = "sender"
+)
@@ -1,12 +1,28 @@
 package response
 
-import "github.com/rbns/gomatrix/event"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/rbns/gomatrix/event"
+)
 
 // Error is the standard JSON error response from Homeservers. It also implements the Golang "error" interface.
 // See http://matrix.org/docs/spec/client_server/r0.2.0.html#api-standards
+//
+// Errors are matched by errcode with errors.Is, so they can be compared against the sentinel errors below:
+//
+//	if errors.Is(err, response.ErrForbidden) {
+//		// ...
+//	}
 type Error struct {
-	ErrCode string `json:"errcode"`
-	Err     string `json:"error"`
+	ErrCode      string `json:"errcode"`
+	Err          string `json:"error"`
+	RetryAfterMs int64  `json:"retry_after_ms,omitempty"` // Only set on M_LIMIT_EXCEEDED errors.
+	SoftLogout   bool   `json:"soft_logout,omitempty"`    // Only set on M_UNKNOWN_TOKEN errors.
+	ConsentURI   string `json:"consent_uri,omitempty"`    // Only set on M_CONSENT_NOT_GIVEN errors.
+	AdminContact string `json:"admin_contact,omitempty"`  // Only set on M_RESOURCE_LIMIT_EXCEEDED errors.
+	LimitType    string `json:"limit_type,omitempty"`     // Only set on M_RESOURCE_LIMIT_EXCEEDED errors.
 }
 
 // Error returns the errcode and error message.
@@ -14,6 +30,52 @@ func (e Error) Error() string {
 	return e.ErrCode + ": " + e.Err
 }
 
+// Is returns true if target is an Error with the same errcode.
+func (e Error) Is(target error) bool {
+	t, ok := target.(Error)
+	return ok && t.ErrCode == e.ErrCode
+}
+
+// Sentinel errors for the errcodes defined by the specification.
+// See https://spec.matrix.org/latest/client-server-api/#standard-error-response
+var (
+	ErrForbidden                   = Error{ErrCode: "M_FORBIDDEN"}
+	ErrUnknownToken                = Error{ErrCode: "M_UNKNOWN_TOKEN"}
+	ErrMissingToken                = Error{ErrCode: "M_MISSING_TOKEN"}
+	ErrUserLocked                  = Error{ErrCode: "M_USER_LOCKED"}
+	ErrBadJSON                     = Error{ErrCode: "M_BAD_JSON"}
+	ErrNotJSON                     = Error{ErrCode: "M_NOT_JSON"}
+	ErrNotFound                    = Error{ErrCode: "M_NOT_FOUND"}
+	ErrLimitExceeded               = Error{ErrCode: "M_LIMIT_EXCEEDED"}
+	ErrUnrecognized                = Error{ErrCode: "M_UNRECOGNIZED"}
+	ErrUnknown                     = Error{ErrCode: "M_UNKNOWN"}
+	ErrUnauthorized                = Error{ErrCode: "M_UNAUTHORIZED"}
+	ErrUserDeactivated             = Error{ErrCode: "M_USER_DEACTIVATED"}
+	ErrUserInUse                   = Error{ErrCode: "M_USER_IN_USE"}
+	ErrInvalidUsername             = Error{ErrCode: "M_INVALID_USERNAME"}
+	ErrRoomInUse                   = Error{ErrCode: "M_ROOM_IN_USE"}
+	ErrInvalidRoomState            = Error{ErrCode: "M_INVALID_ROOM_STATE"}
+	ErrThreePIDInUse               = Error{ErrCode: "M_THREEPID_IN_USE"}
+	ErrThreePIDNotFound            = Error{ErrCode: "M_THREEPID_NOT_FOUND"}
+	ErrThreePIDAuthFailed          = Error{ErrCode: "M_THREEPID_AUTH_FAILED"}
+	ErrThreePIDDenied              = Error{ErrCode: "M_THREEPID_DENIED"}
+	ErrServerNotTrusted            = Error{ErrCode: "M_SERVER_NOT_TRUSTED"}
+	ErrUnsupportedRoomVersion      = Error{ErrCode: "M_UNSUPPORTED_ROOM_VERSION"}
+	ErrIncompatibleRoomVersion     = Error{ErrCode: "M_INCOMPATIBLE_ROOM_VERSION"}
+	ErrBadState                    = Error{ErrCode: "M_BAD_STATE"}
+	ErrGuestAccessForbidden        = Error{ErrCode: "M_GUEST_ACCESS_FORBIDDEN"}
+	ErrCaptchaNeeded               = Error{ErrCode: "M_CAPTCHA_NEEDED"}
+	ErrCaptchaInvalid              = Error{ErrCode: "M_CAPTCHA_INVALID"}
+	ErrMissingParam                = Error{ErrCode: "M_MISSING_PARAM"}
+	ErrInvalidParam                = Error{ErrCode: "M_INVALID_PARAM"}
+	ErrTooLarge                    = Error{ErrCode: "M_TOO_LARGE"}
+	ErrExclusive                   = Error{ErrCode: "M_EXCLUSIVE"}
+	ErrResourceLimitExceeded       = Error{ErrCode: "M_RESOURCE_LIMIT_EXCEEDED"}
+	ErrCannotLeaveServerNoticeRoom = Error{ErrCode: "M_CANNOT_LEAVE_SERVER_NOTICE_ROOM"}
+	ErrWeakPassword                = Error{ErrCode: "M_WEAK_PASSWORD"}
+	ErrConsentNotGiven             = Error{ErrCode: "M_CONSENT_NOT_GIVEN"}
+)
+
 // CreateFilter is the JSON response for http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-user-userid-filter
 type CreateFilter struct {
 	FilterID string `json:"filter_id"`
@@ -24,6 +86,16 @@ type Versions struct {
 	Versions []string `json:"versions"`
 }
 
+// ClientWellKnown is the JSON response for https://spec.matrix.org/latest/client-server-api/#getwell-knownmatrixclient
+type ClientWellKnown struct {
+	Homeserver struct {
+		BaseURL string `json:"base_url"`
+	} `json:"m.homeserver"`
+	IdentityServer *struct {
+		BaseURL string `json:"base_url"`
+	} `json:"m.identity_server,omitempty"`
+}
+
 // JoinRoom is the JSON response for http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-rooms-roomid-join
 type JoinRoom struct {
 	RoomID string `json:"room_id"`
@@ -50,6 +122,60 @@ type UnbanUser struct{}
 // Typing is the JSON response for https://matrix.org/docs/spec/client_server/r0.2.0.html#put-matrix-client-r0-rooms-roomid-typing-userid
 type Typing struct{}
 
+// PublicRooms is the JSON response for https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3publicrooms
+type PublicRooms struct {
+	Chunk                  []PublicRoom `json:"chunk"`
+	NextBatch              string       `json:"next_batch,omitempty"`
+	PrevBatch              string       `json:"prev_batch,omitempty"`
+	TotalRoomCountEstimate int          `json:"total_room_count_estimate,omitempty"`
+}
+
+// PublicRoom is a room published in the room directory. It is a JSON object used in
+// https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3publicrooms
+type PublicRoom struct {
+	RoomID           string   `json:"room_id"`
+	Name             string   `json:"name,omitempty"`
+	Topic            string   `json:"topic,omitempty"`
+	CanonicalAlias   string   `json:"canonical_alias,omitempty"`
+	Aliases          []string `json:"aliases,omitempty"`
+	AvatarURL        string   `json:"avatar_url,omitempty"`
+	NumJoinedMembers int      `json:"num_joined_members"`
+	WorldReadable    bool     `json:"world_readable"`
+	GuestCanJoin     bool     `json:"guest_can_join"`
+	JoinRule         string   `json:"join_rule,omitempty"`
+	RoomType         string   `json:"room_type,omitempty"`
+}
+
+// RoomVisibility is the JSON response for https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3directorylistroomroomid
+type RoomVisibility struct {
+	Visibility string `json:"visibility"`
+}
+
+// SetRoomVisibility is the JSON response for https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3directorylistroomroomid
+type SetRoomVisibility struct{}
+
+// CreateAlias is the JSON response for https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3directoryroomroomalias
+type CreateAlias struct{}
+
+// ResolveAlias is the JSON response for https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3directoryroomroomalias
+type ResolveAlias struct {
+	RoomID  string   `json:"room_id"`
+	Servers []string `json:"servers"`
+}
+
+// DeleteAlias is the JSON response for https://spec.matrix.org/latest/client-server-api/#delete_matrixclientv3directoryroomroomalias
+type DeleteAlias struct{}
+
+// RoomAliases is the JSON response for https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3roomsroomidaliases
+type RoomAliases struct {
+	Aliases []string `json:"aliases"`
+}
+
+// Tags is the JSON response for https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3useruseridroomsroomidtags
+type Tags struct {
+	Tags map[string]event.TagInfo `json:"tags"`
+}
+
 // JoinedRooms is the JSON response for TODO-SPEC https://github.com/matrix-org/synapse/pull/1680
 type JoinedRooms struct {
 	JoinedRooms []string `json:"joined_rooms"`
@@ -80,18 +206,48 @@ type MediaUpload struct {
 	ContentURI string `json:"content_uri"`
 }
 
+// CreateMXC is the JSON response for https://spec.matrix.org/latest/client-server-api/#post_matrixmediav1create
+type CreateMXC struct {
+	ContentURI      string `json:"content_uri"`
+	UnusedExpiresAt int64  `json:"unused_expires_at,omitempty"` // The unix timestamp in milliseconds when the URI expires if nothing is uploaded to it
+}
+
+// MediaConfig is the JSON response for https://spec.matrix.org/latest/client-server-api/#get_matrixclientv1mediaconfig
+type MediaConfig struct {
+	UploadSize int64 `json:"m.upload.size,omitempty"` // The maximum upload size in bytes, or 0 if the homeserver doesn't say
+}
+
+// PreviewURL is the JSON response for https://spec.matrix.org/latest/client-server-api/#get_matrixclientv1mediapreview_url
+// It holds the OpenGraph data of the page. Fields the page doesn't set are empty.
+type PreviewURL struct {
+	Title       string `json:"og:title,omitempty"`
+	Description string `json:"og:description,omitempty"`
+	Type        string `json:"og:type,omitempty"`
+	URL         string `json:"og:url,omitempty"`
+	SiteName    string `json:"og:site_name,omitempty"`
+	Image       string `json:"og:image,omitempty"` // The MXC URI of the image
+	ImageType   string `json:"og:image:type,omitempty"`
+	ImageWidth  int    `json:"og:image:width,omitempty"`
+	ImageHeight int    `json:"og:image:height,omitempty"`
+	ImageSize   int64  `json:"matrix:image:size,omitempty"`
+}
+
 // UserInteractive is the JSON response for https://matrix.org/docs/spec/client_server/r0.2.0.html#user-interactive-authentication-api
 type UserInteractive struct {
-	Flows []struct {
-		Stages []string `json:"stages"`
-	} `json:"flows"`
+	Flows     []UIAFlow              `json:"flows"`
 	Params    map[string]interface{} `json:"params"`
-	Session   string                 `json:"string"`
+	Session   string                 `json:"session"`
 	Completed []string               `json:"completed"`
 	ErrCode   string                 `json:"errcode"`
 	Error     string                 `json:"error"`
 }
 
+// UIAFlow is a list of stages which, once all completed, authenticate the request. It is a JSON object used in
+// https://matrix.org/docs/spec/client_server/r0.2.0.html#user-interactive-authentication-api
+type UIAFlow struct {
+	Stages []string `json:"stages"`
+}
+
 // HasSingleStageFlow returns true if there exists at least 1 Flow with a single stage of stageName.
 func (r UserInteractive) HasSingleStageFlow(stageName string) bool {
 	for _, f := range r.Flows {
@@ -102,26 +258,170 @@ func (r UserInteractive) HasSingleStageFlow(stageName string) bool {
 	return false
 }
 
+// HasCompleted returns true if the given stage has already been completed in this session.
+func (r UserInteractive) HasCompleted(stageName string) bool {
+	for _, s := range r.Completed {
+		if s == stageName {
+			return true
+		}
+	}
+	return false
+}
+
+// TermsPolicy is a policy which the user must agree to during m.login.terms authentication. It is a JSON object used in
+// https://spec.matrix.org/latest/client-server-api/#terms-of-service-at-registration
+type TermsPolicy struct {
+	Version string
+	// The policy's name and URL for every language it is available in, keyed by language code.
+	Translations map[string]TermsPolicyTranslation
+}
+
+// TermsPolicyTranslation is the name and URL of a TermsPolicy in a single language.
+type TermsPolicyTranslation struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// UnmarshalJSON unmarshals a policy, whose translations are keyed by language alongside its version.
+func (p *TermsPolicy) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	p.Translations = make(map[string]TermsPolicyTranslation)
+	for k, v := range raw {
+		if k == "version" {
+			if err := json.Unmarshal(v, &p.Version); err != nil {
+				return err
+			}
+			continue
+		}
+		var t TermsPolicyTranslation
+		if err := json.Unmarshal(v, &t); err != nil {
+			return err
+		}
+		p.Translations[k] = t
+	}
+	return nil
+}
+
 // UserDisplayName is the JSON response for https://matrix.org/docs/spec/client_server/r0.2.0.html#get-matrix-client-r0-profile-userid-displayname
 type UserDisplayName struct {
 	DisplayName string `json:"displayname"`
 }
 
+// Profile is the JSON response for https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3profileuserid
+type Profile struct {
+	DisplayName string `json:"displayname,omitempty"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+}
+
+// Presence is the JSON response for https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3presenceuseridstatus
+type Presence struct {
+	Presence        string `json:"presence"`
+	LastActiveAgo   int64  `json:"last_active_ago,omitempty"`
+	StatusMsg       string `json:"status_msg,omitempty"`
+	CurrentlyActive bool   `json:"currently_active,omitempty"`
+}
+
 // Register is the JSON response for http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-register
 type Register struct {
 	AccessToken  string `json:"access_token"`
 	DeviceID     string `json:"device_id"`
 	HomeServer   string `json:"home_server"`
 	RefreshToken string `json:"refresh_token"`
+	ExpiresInMs  int64  `json:"expires_in_ms,omitempty"` // How long the access token is valid for, if it expires.
 	UserID       string `json:"user_id"`
 }
 
 // Login is the JSON response for http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-login
 type Login struct {
-	AccessToken string `json:"access_token"`
-	DeviceID    string `json:"device_id"`
-	HomeServer  string `json:"home_server"`
-	UserID      string `json:"user_id"`
+	AccessToken  string           `json:"access_token"`
+	DeviceID     string           `json:"device_id"`
+	HomeServer   string           `json:"home_server"`
+	RefreshToken string           `json:"refresh_token,omitempty"`
+	ExpiresInMs  int64            `json:"expires_in_ms,omitempty"` // How long the access token is valid for, if it expires.
+	UserID       string           `json:"user_id"`
+	WellKnown    *ClientWellKnown `json:"well_known,omitempty"`
+}
+
+// Refresh is the JSON response for https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3refresh
+type Refresh struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresInMs  int64  `json:"expires_in_ms,omitempty"`
+}
+
+// LoginFlows is the JSON response for https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3login
+type LoginFlows struct {
+	Flows []LoginFlow `json:"flows"`
+}
+
+// LoginFlow is a single login type supported by the homeserver. It is a JSON object used in
+// https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3login
+type LoginFlow struct {
+	Type              string             `json:"type"`
+	IdentityProviders []IdentityProvider `json:"identity_providers,omitempty"` // Only set for m.login.sso
+	GetLoginToken     bool               `json:"get_login_token,omitempty"`    // Only set for m.login.token
+}
+
+// IdentityProvider is an SSO identity provider. It is a JSON object used in
+// https://spec.matrix.org/latest/client-server-api/#definition-mloginsso-flow-schema
+type IdentityProvider struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Icon  string `json:"icon,omitempty"`
+	Brand string `json:"brand,omitempty"`
+}
+
+// HasFlow returns true if the homeserver supports the given login type.
+func (r LoginFlows) HasFlow(loginType string) bool {
+	return r.Flow(loginType) != nil
+}
+
+// Flow returns the flow for the given login type, or nil if the homeserver does not support it.
+func (r LoginFlows) Flow(loginType string) *LoginFlow {
+	for i := range r.Flows {
+		if r.Flows[i].Type == loginType {
+			return &r.Flows[i]
+		}
+	}
+	return nil
+}
+
+// WhoAmI is the JSON response for https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3accountwhoami
+type WhoAmI struct {
+	UserID   string `json:"user_id"`
+	DeviceID string `json:"device_id,omitempty"`
+	IsGuest  bool   `json:"is_guest,omitempty"`
+}
+
+// DeactivateAccount is the JSON response for https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3accountdeactivate
+type DeactivateAccount struct {
+	IDServerUnbindResult string `json:"id_server_unbind_result"` // "success" or "no-support"
+}
+
+// ThreePIDs is the JSON response for https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3account3pid
+type ThreePIDs struct {
+	ThreePIDs []struct {
+		Medium      string `json:"medium"`
+		Address     string `json:"address"`
+		ValidatedAt int64  `json:"validated_at"`
+		AddedAt     int64  `json:"added_at"`
+	} `json:"threepids"`
+}
+
+// RequestToken is the JSON response for https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3account3pidemailrequesttoken
+// and https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3account3pidmsisdnrequesttoken
+type RequestToken struct {
+	SID       string `json:"sid"`
+	SubmitURL string `json:"submit_url,omitempty"`
+}
+
+// UnbindThreePID is the JSON response for https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3account3pidunbind
+// and https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3account3piddelete
+type UnbindThreePID struct {
+	IDServerUnbindResult string `json:"id_server_unbind_result"` // "success" or "no-support"
 }
 
 // Logout is the JSON response for http://matrix.org/docs/spec/client_server/r0.2.0.html#post-matrix-client-r0-logout
@@ -151,6 +451,9 @@ type Sync struct {
 				Limited   bool          `json:"limited"`
 				PrevBatch string        `json:"prev_batch"`
 			} `json:"timeline"`
+			AccountData struct {
+				Events []event.Event `json:"events"`
+			} `json:"account_data"`
 		} `json:"leave"`
 		Join map[string]struct {
 			State struct {
@@ -164,6 +467,9 @@ type Sync struct {
 			Ephemeral struct {
 				Events []event.Event `json:"events"`
 			} `json:"ephemeral"`
+			AccountData struct {
+				Events []event.Event `json:"events"`
+			} `json:"account_data"`
 			UnreadNotifications struct {
 				HighlightCount    int `json:"highlight_count"`
 				NotificationCount int `json:"notification_count"`
@@ -177,9 +483,128 @@ type Sync struct {
 	} `json:"rooms"`
 }
 
+// Device is the JSON response for https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3devicesdeviceid
+type Device struct {
+	DeviceID    string `json:"device_id"`
+	DisplayName string `json:"display_name,omitempty"`
+	LastSeenIP  string `json:"last_seen_ip,omitempty"`
+	LastSeenTS  int64  `json:"last_seen_ts,omitempty"` // The unix timestamp in milliseconds when the device was last seen
+}
+
+// LastSeen returns the time the device was last seen, or the zero time if the homeserver doesn't know.
+func (d Device) LastSeen() time.Time {
+	if d.LastSeenTS == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, d.LastSeenTS*int64(time.Millisecond))
+}
+
+// Devices is the JSON response for https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3devices
+type Devices struct {
+	Devices []Device `json:"devices"`
+}
+
 type TurnServer struct {
 	Username string   `json:"username"`
 	Password string   `json:"password"`
 	TTL      int      `json:"ttl"`
 	URIs     []string `json:"uris"`
 }
+
+// PushRules is the JSON response for https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3pushrules
+type PushRules struct {
+	Global event.PushRuleset `json:"global"`
+}
+
+// Pushers is the JSON response for https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3pushers
+type Pushers struct {
+	Pushers []Pusher `json:"pushers"`
+}
+
+// Pusher is a single pusher of the user.
+type Pusher struct {
+	PushKey           string `json:"pushkey"`
+	Kind              string `json:"kind"`
+	AppID             string `json:"app_id"`
+	AppDisplayName    string `json:"app_display_name"`
+	DeviceDisplayName string `json:"device_display_name"`
+	ProfileTag        string `json:"profile_tag,omitempty"`
+	Lang              string `json:"lang"`
+	Data              struct {
+		URL    string `json:"url,omitempty"`
+		Format string `json:"format,omitempty"`
+	} `json:"data"`
+}
+
+// Notifications is the JSON response for https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3notifications
+type Notifications struct {
+	NextToken     string         `json:"next_token,omitempty"` // Pass as from to get the next page. Empty on the last page.
+	Notifications []Notification `json:"notifications"`
+}
+
+// Notification is a single event the user was notified about.
+type Notification struct {
+	Actions    event.PushActions `json:"actions"`
+	Event      event.Event       `json:"event"`
+	ProfileTag string            `json:"profile_tag,omitempty"`
+	Read       bool              `json:"read"`
+	RoomID     string            `json:"room_id"`
+	Ts         int64             `json:"ts"` // The unix timestamp in milliseconds when the event was notified about
+}
+
+// Search is the JSON response for https://spec.matrix.org/latest/client-server-api/#post_matrixclientv3search
+type Search struct {
+	SearchCategories struct {
+		RoomEvents SearchRoomEvents `json:"room_events"`
+	} `json:"search_categories"`
+}
+
+// SearchRoomEvents is the result of searching room events.
+type SearchRoomEvents struct {
+	Count      int                               `json:"count"`      // An approximate count of the results
+	Highlights []string                          `json:"highlights"` // Words to highlight in the results
+	NextBatch  string                            `json:"next_batch,omitempty"`
+	Results    []SearchResult                    `json:"results"`
+	State      map[string][]event.Event          `json:"state,omitempty"`  // The current state of each room, if include_state was set
+	Groups     map[string]map[string]SearchGroup `json:"groups,omitempty"` // Group key to group value to group
+}
+
+// SearchResult is a single result of a search.
+type SearchResult struct {
+	Rank    float64              `json:"rank"`
+	Result  event.Event          `json:"result"`
+	Context *SearchResultContext `json:"context,omitempty"`
+}
+
+// SearchResultContext is the context of a search result, if the search asked for it.
+type SearchResultContext struct {
+	Start        string             `json:"start,omitempty"`
+	End          string             `json:"end,omitempty"`
+	EventsBefore []event.Event      `json:"events_before"`
+	EventsAfter  []event.Event      `json:"events_after"`
+	ProfileInfo  map[string]Profile `json:"profile_info,omitempty"` // User ID to profile
+}
+
+// SearchGroup is a group of search results.
+type SearchGroup struct {
+	NextBatch string   `json:"next_batch,omitempty"`
+	Order     int      `json:"order"`
+	Results   []string `json:"results"` // The event IDs of the results in the group
+}
+
+// EventContext is the JSON response for https://spec.matrix.org/latest/client-server-api/#get_matrixclientv3roomsroomidcontexteventid
+type EventContext struct {
+	Start        string        `json:"start"` // A token to paginate backwards from with Messages
+	End          string        `json:"end"`   // A token to paginate forwards from with Messages
+	Event        event.Event   `json:"event"`
+	EventsBefore []event.Event `json:"events_before"` // Newest first
+	EventsAfter  []event.Event `json:"events_after"`  // Oldest first
+	State        []event.Event `json:"state"`         // The state of the room at the last event returned
+}
+
+// Relations is the JSON response for https://spec.matrix.org/latest/client-server-api/#get_matrixclientv1roomsroomidrelationseventid
+type Relations struct {
+	Chunk     []event.Event `json:"chunk"`
+	NextBatch string        `json:"next_batch,omitempty"` // Pass as from to get the next page. Empty on the last page.
+	PrevBatch string        `json:"prev_batch,omitempty"`
+}
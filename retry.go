@@ -0,0 +1,98 @@
+package gomatrix
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rbns/gomatrix/response"
+)
+
+// RetryPolicy controls how a Client retries requests which the homeserver rejected because of rate limiting
+// (HTTP 429, usually with an errcode of M_LIMIT_EXCEEDED).
+//
+// Only idempotent requests (GET, PUT and DELETE) are retried. Events are sent with PUT and a transaction ID, so
+// retrying SendMessageEvent will never send a message twice.
+type RetryPolicy struct {
+	// The maximum number of times a single request will be retried. Zero disables retries.
+	MaxRetries int
+	// The time to wait before the first retry if the homeserver did not say how long to wait. It is doubled for
+	// every further retry.
+	MinBackoff time.Duration
+	// The longest the client will wait before retrying. If the homeserver asks for a longer wait than this, the
+	// error is returned instead. Zero means there is no limit.
+	MaxBackoff time.Duration
+	// OnRetry is called, if set, just before the client waits to retry a request. attempt starts at 1.
+	OnRetry func(attempt int, wait time.Duration, err HTTPError)
+}
+
+// DefaultRetryPolicy returns a RetryPolicy which retries a request up to 5 times, waiting 1 second before the first
+// retry and never waiting longer than 1 minute.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxRetries: 5,
+		MinBackoff: time.Second,
+		MaxBackoff: time.Minute,
+	}
+}
+
+// shouldRetry returns how long to wait before retrying a request which failed with err, and whether it should be
+// retried at all. attempt is the number of the request which just failed, starting at 1.
+func (p *RetryPolicy) shouldRetry(method string, attempt int, header http.Header, err error) (time.Duration, bool) {
+	if p == nil || attempt > p.MaxRetries {
+		return 0, false
+	}
+	switch method {
+	case "GET", "PUT", "DELETE":
+	default:
+		return 0, false
+	}
+	httpErr, ok := err.(HTTPError)
+	if !ok || httpErr.Code != 429 {
+		return 0, false
+	}
+
+	hinted := retryAfter(header, httpErr.WrappedError)
+	wait := hinted
+	if wait == 0 {
+		wait = p.MinBackoff << uint(attempt-1)
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		if hinted > 0 {
+			// The homeserver won't accept the request any sooner, so retrying early is pointless.
+			return 0, false
+		}
+		wait = p.MaxBackoff
+	}
+
+	// Add up to 25% jitter so that clients which were throttled together don't all retry at the same moment.
+	if wait > 0 {
+		wait += time.Duration(rand.Int63n(int64(wait)/4 + 1))
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	return wait, true
+}
+
+// retryAfter returns how long the homeserver asked the client to wait, either through the retry_after_ms field of
+// the error or the Retry-After header. Returns 0 if the homeserver did not say.
+func retryAfter(header http.Header, wrapped error) time.Duration {
+	if respErr, ok := wrapped.(response.Error); ok && respErr.RetryAfterMs > 0 {
+		return time.Duration(respErr.RetryAfterMs) * time.Millisecond
+	}
+	h := header.Get("Retry-After")
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(h); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -6,6 +6,17 @@ import "github.com/rbns/gomatrix/event"
 type Room struct {
 	ID    string
 	State map[string]map[string]*event.Event
+	Tags  map[string]event.TagInfo // The user's tags for this room, from the latest m.tag account data event.
+	// The latest unthreaded or main thread read receipt of each user, keyed by user ID.
+	Receipts map[string]ReadReceipt
+	// The IDs of the users who are currently typing, from the latest m.typing event.
+	Typing []string
+}
+
+// ReadReceipt is the latest event a user has read in a room.
+type ReadReceipt struct {
+	EventID string
+	Ts      int64 // The unix timestamp in milliseconds when the receipt was sent
 }
 
 // UpdateState updates the room's current state with the given Event. This will clobber events based
@@ -18,6 +29,62 @@ func (room Room) UpdateState(e *event.Event) {
 	room.State[e.Type][*e.StateKey] = e
 }
 
+// HasTag returns true if the user has tagged this room with the given tag, e.g. event.TagFavourite.
+func (room Room) HasTag(tag string) bool {
+	_, ok := room.Tags[tag]
+	return ok
+}
+
+// UpdateReceipts updates the room's read receipts with the content of an m.receipt event. Receipts for threads
+// other than the main one are ignored, as are receipts older than the user's current one.
+func (room *Room) UpdateReceipts(r event.Receipt) {
+	if room.Receipts == nil {
+		room.Receipts = make(map[string]ReadReceipt)
+	}
+	for eventID, types := range r {
+		for receiptType, users := range types {
+			if receiptType != event.ReceiptTypeRead && receiptType != event.ReceiptTypeReadPrivate {
+				continue
+			}
+			for userID, info := range users {
+				if info.ThreadID != "" && info.ThreadID != event.ThreadIDMain {
+					continue
+				}
+				if cur, ok := room.Receipts[userID]; ok && cur.Ts > info.Ts {
+					continue
+				}
+				room.Receipts[userID] = ReadReceipt{EventID: eventID, Ts: info.Ts}
+			}
+		}
+	}
+}
+
+// IsTyping returns true if the given user is currently typing in this room.
+func (room Room) IsTyping(userID string) bool {
+	for _, id := range room.Typing {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadUpTo returns the ID of the latest event the given user has read, or "" if no receipt has been seen for them.
+func (room Room) ReadUpTo(userID string) string {
+	return room.Receipts[userID].EventID
+}
+
+// ReadBy returns the IDs of the users whose latest read receipt is for the given event.
+func (room Room) ReadBy(eventID string) []string {
+	var userIDs []string
+	for userID, r := range room.Receipts {
+		if r.EventID == eventID {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs
+}
+
 // GetStateEvent returns the state event for the given type/state_key combo, or nil.
 func (room Room) GetStateEvent(eventType string, stateKey string) *event.Event {
 	stateEventMap, _ := room.State[eventType]
@@ -41,7 +108,8 @@ func (room Room) GetMembershipState(userID string) string {
 func NewRoom(roomID string) *Room {
 	// Init the State map and return a pointer to the Room
 	return &Room{
-		ID:    roomID,
-		State: make(map[string]map[string]*event.Event),
+		ID:       roomID,
+		State:    make(map[string]map[string]*event.Event),
+		Receipts: make(map[string]ReadReceipt),
 	}
 }
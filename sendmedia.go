@@ -0,0 +1,298 @@
+package gomatrix
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // register the decoders for image.Decode
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rbns/gomatrix/event"
+	"github.com/rbns/gomatrix/response"
+)
+
+// The largest thumbnail SendImageFile generates. Images which already fit are not thumbnailed.
+const (
+	thumbnailMaxWidth  = 800
+	thumbnailMaxHeight = 600
+)
+
+// The largest image, in pixels, SendImageFile decodes to generate a thumbnail and BlurHash. A small file can declare
+// huge dimensions, and decoding it would allocate memory for every pixel.
+const maxDecodePixels = 50 * 1000 * 1000
+
+// SendImageFile uploads an image and sends it into the given room as an m.image message, with its dimensions, MIME
+// type, size, a thumbnail and a BlurHash filled in. name is used as the body of the message. If content is nil, the
+// file at path name is sent instead.
+//
+// The image is read into memory to be decoded. PNG, JPEG and GIF images are supported; other formats are sent without
+// dimensions or a thumbnail. Images larger than 50 megapixels are sent without a thumbnail or BlurHash.
+func (cli *Client) SendImageFile(roomID, name string, content io.Reader) (*response.SendEvent, error) {
+	return cli.SendImageFileContext(context.Background(), roomID, name, content)
+}
+
+// SendImageFileContext is like SendImageFile, but the requests are bound to ctx.
+func (cli *Client) SendImageFileContext(ctx context.Context, roomID, name string, content io.Reader) (*response.SendEvent, error) {
+	content, closeContent, err := openMediaFile(name, content)
+	if err != nil {
+		return nil, err
+	}
+	defer closeContent()
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+	filename := filepath.Base(name)
+
+	info := event.ImageInfo{
+		Mimetype: detectMIME(filename, data),
+		Size:     uint(len(data)),
+	}
+	if config, format, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		info.Width, info.Height = uint(config.Width), uint(config.Height)
+		info.Mimetype = "image/" + format
+
+		if int64(config.Width)*int64(config.Height) <= maxDecodePixels {
+			if img, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+				info.Blurhash = blurhash(scaleImage(img, 64, 64), 4, 3)
+
+				if config.Width > thumbnailMaxWidth || config.Height > thumbnailMaxHeight {
+					thumbURL, thumbInfo, err := cli.uploadThumbnail(ctx, img, format, filename)
+					if err != nil {
+						return nil, err
+					}
+					info.ThumbnailURL, info.ThumbnailInfo = thumbURL, thumbInfo
+				}
+			}
+		}
+	}
+
+	resp, err := cli.UploadContext(ctx, &UploadRequest{
+		Content:       bytes.NewReader(data),
+		ContentType:   info.Mimetype,
+		ContentLength: int64(len(data)),
+		Filename:      filename,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cli.SendMessageEventContext(ctx, roomID, "m.room.message", event.ImageMessage{
+		MsgType: "m.image",
+		Body:    filename,
+		URL:     resp.ContentURI,
+		Info:    info,
+	})
+}
+
+// SendFile uploads a file and sends it into the given room as an m.file message, with its MIME type and size filled
+// in. name is used as the body and filename of the message. If content is nil, the file at path name is sent instead.
+// The file is streamed to the homeserver.
+func (cli *Client) SendFile(roomID, name string, content io.Reader) (*response.SendEvent, error) {
+	return cli.SendFileContext(context.Background(), roomID, name, content)
+}
+
+// SendFileContext is like SendFile, but the requests are bound to ctx.
+func (cli *Client) SendFileContext(ctx context.Context, roomID, name string, content io.Reader) (*response.SendEvent, error) {
+	mxc, mimetype, size, err := cli.uploadMediaFile(ctx, name, content)
+	if err != nil {
+		return nil, err
+	}
+	filename := filepath.Base(name)
+	return cli.SendMessageEventContext(ctx, roomID, "m.room.message", event.FileMessage{
+		MsgType:  "m.file",
+		Body:     filename,
+		Filename: filename,
+		URL:      mxc,
+		Info: event.FileInfo{
+			Mimetype: mimetype,
+			Size:     int(size),
+		},
+	})
+}
+
+// SendAudio uploads an audio file and sends it into the given room as an m.audio message, with its MIME type, size
+// and the given duration filled in. The duration can be 0 if it isn't known. name is used as the body of the message.
+// If content is nil, the file at path name is sent instead. The file is streamed to the homeserver.
+func (cli *Client) SendAudio(roomID, name string, content io.Reader, duration time.Duration) (*response.SendEvent, error) {
+	return cli.SendAudioContext(context.Background(), roomID, name, content, duration)
+}
+
+// SendAudioContext is like SendAudio, but the requests are bound to ctx.
+func (cli *Client) SendAudioContext(ctx context.Context, roomID, name string, content io.Reader, duration time.Duration) (*response.SendEvent, error) {
+	mxc, mimetype, size, err := cli.uploadMediaFile(ctx, name, content)
+	if err != nil {
+		return nil, err
+	}
+	return cli.SendMessageEventContext(ctx, roomID, "m.room.message", event.AudioMessage{
+		MsgType: "m.audio",
+		Body:    filepath.Base(name),
+		URL:     mxc,
+		Info: event.AudioInfo{
+			Duration: int(duration / time.Millisecond),
+			Mimetype: mimetype,
+			Size:     int(size),
+		},
+	})
+}
+
+// uploadMediaFile streams a file to the content repository, and returns its MXC URI, MIME type and size.
+func (cli *Client) uploadMediaFile(ctx context.Context, name string, content io.Reader) (mxc, mimetype string, size int64, err error) {
+	content, closeContent, err := openMediaFile(name, content)
+	if err != nil {
+		return
+	}
+	defer closeContent()
+
+	var length int64
+	if f, ok := content.(*os.File); ok {
+		if stat, err := f.Stat(); err == nil {
+			length = stat.Size()
+		}
+	}
+	br := bufio.NewReader(content)
+	head, _ := br.Peek(512)
+	mimetype = detectMIME(filepath.Base(name), head)
+
+	counter := &countingReader{r: br}
+	resp, err := cli.UploadContext(ctx, &UploadRequest{
+		Content:       counter,
+		ContentType:   mimetype,
+		ContentLength: length,
+		Filename:      filepath.Base(name),
+	})
+	if err != nil {
+		return
+	}
+	return resp.ContentURI, mimetype, counter.count(), nil
+}
+
+// uploadThumbnail scales img down to fit the maximum thumbnail size and uploads it. Images which may be transparent
+// are thumbnailed as PNG, others as JPEG.
+func (cli *Client) uploadThumbnail(ctx context.Context, img image.Image, format, filename string) (string, *event.ImageInfo, error) {
+	thumb := scaleImage(img, thumbnailMaxWidth, thumbnailMaxHeight)
+
+	var buf bytes.Buffer
+	mimetype := "image/jpeg"
+	var err error
+	if format == "png" || format == "gif" {
+		mimetype = "image/png"
+		err = png.Encode(&buf, thumb)
+	} else {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 80})
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	info := &event.ImageInfo{
+		Width:    uint(thumb.Bounds().Dx()),
+		Height:   uint(thumb.Bounds().Dy()),
+		Mimetype: mimetype,
+		Size:     uint(buf.Len()),
+	}
+	resp, err := cli.UploadContext(ctx, &UploadRequest{
+		Content:       &buf,
+		ContentType:   mimetype,
+		ContentLength: int64(buf.Len()),
+		Filename:      "thumbnail-" + strings.TrimSuffix(filename, filepath.Ext(filename)) + "." + strings.TrimPrefix(mimetype, "image/"),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return resp.ContentURI, info, nil
+}
+
+// scaleImage scales img to fit within width x height, keeping its aspect ratio, by averaging the pixels each new
+// pixel covers. Images which already fit are only converted.
+func scaleImage(img image.Image, width, height int) *image.NRGBA {
+	bounds := img.Bounds()
+	if bounds.Dx() <= width && bounds.Dy() <= height {
+		dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+		return dst
+	}
+	if bounds.Dx()*height > bounds.Dy()*width {
+		height = maxInt(bounds.Dy()*width/bounds.Dx(), 1)
+	} else {
+		width = maxInt(bounds.Dx()*height/bounds.Dy(), 1)
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := maxInt(bounds.Min.Y+(y+1)*bounds.Dy()/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := maxInt(bounds.Min.X+(x+1)*bounds.Dx()/width, x0+1)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBA64Model.Convert(img.At(sx, sy)).(color.NRGBA64)
+					r, g, b, a = r+uint64(c.R), g+uint64(c.G), b+uint64(c.B), a+uint64(c.A)
+					n++
+				}
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{uint8(r / n >> 8), uint8(g / n >> 8), uint8(b / n >> 8), uint8(a / n >> 8)})
+		}
+	}
+	return dst
+}
+
+// openMediaFile opens the file at path name if content is nil.
+func openMediaFile(name string, content io.Reader) (io.Reader, func(), error) {
+	if content != nil {
+		return content, func() {}, nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func() { f.Close() }, nil
+}
+
+// detectMIME returns the MIME type of a file from its extension, or else from its first bytes.
+func detectMIME(filename string, head []byte) string {
+	if t := mime.TypeByExtension(filepath.Ext(filename)); t != "" {
+		if mediaType, _, err := mime.ParseMediaType(t); err == nil {
+			return mediaType
+		}
+	}
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	return mediaType
+}
+
+// countingReader counts the bytes read through it. The count is safe to read from another goroutine.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+func (c *countingReader) count() int64 {
+	return atomic.LoadInt64(&c.n)
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
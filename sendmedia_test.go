@@ -0,0 +1,182 @@
+package gomatrix
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestClient_SendImageFile(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 1000, 500))
+	for x := 0; x < 1000; x++ {
+		for y := 0; y < 500; y++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var file bytes.Buffer
+	if err := png.Encode(&file, img); err != nil {
+		t.Fatalf("png.Encode: %s", err)
+	}
+
+	var uploads []string
+	var sent struct {
+		MsgType string `json:"msgtype"`
+		Body    string `json:"body"`
+		URL     string `json:"url"`
+		Info    struct {
+			W             int    `json:"w"`
+			H             int    `json:"h"`
+			Mimetype      string `json:"mimetype"`
+			Size          int    `json:"size"`
+			ThumbnailURL  string `json:"thumbnail_url"`
+			ThumbnailInfo struct {
+				W        int    `json:"w"`
+				H        int    `json:"h"`
+				Mimetype string `json:"mimetype"`
+			} `json:"thumbnail_info"`
+			Blurhash string `json:"xyz.amorgan.blurhash"`
+		} `json:"info"`
+	}
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == "POST" && req.URL.Path == "/_matrix/media/v3/upload":
+			body, _ := ioutil.ReadAll(req.Body)
+			if _, err := png.DecodeConfig(bytes.NewReader(body)); err != nil {
+				t.Errorf("SendImageFile: uploaded a file which isn't a PNG: %s", err)
+			}
+			uploads = append(uploads, req.URL.Query().Get("filename"))
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(fmt.Sprintf(`{"content_uri":"mxc://example.org/%d"}`, len(uploads)))),
+			}, nil
+		case req.Method == "PUT" && strings.HasPrefix(req.URL.Path, "/_matrix/client/r0/rooms/!foo:bar/send/m.room.message/"):
+			body, _ := ioutil.ReadAll(req.Body)
+			if err := json.Unmarshal(body, &sent); err != nil {
+				t.Fatalf("SendImageFile: invalid body %s", body)
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"event_id":"$image:bar"}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	size := file.Len()
+	if _, err := cli.SendImageFile("!foo:bar", "/tmp/gradient.png", &file); err != nil {
+		t.Fatalf("SendImageFile: error, got %s", err.Error())
+	}
+
+	if len(uploads) != 2 || uploads[0] != "thumbnail-gradient.png" || uploads[1] != "gradient.png" {
+		t.Errorf("SendImageFile: got uploads %v", uploads)
+	}
+	if sent.MsgType != "m.image" || sent.Body != "gradient.png" || sent.URL != "mxc://example.org/2" {
+		t.Errorf("SendImageFile: got message %+v", sent)
+	}
+	info := sent.Info
+	if info.W != 1000 || info.H != 500 || info.Mimetype != "image/png" || info.Size != size {
+		t.Errorf("SendImageFile: got info %+v", info)
+	}
+	if info.ThumbnailURL != "mxc://example.org/1" || info.ThumbnailInfo.W != 800 || info.ThumbnailInfo.H != 400 {
+		t.Errorf("SendImageFile: got thumbnail %s %+v", info.ThumbnailURL, info.ThumbnailInfo)
+	}
+	if len(info.Blurhash) != 28 || info.Blurhash[0] != 'L' {
+		t.Errorf("SendImageFile: got blurhash %q", info.Blurhash)
+	}
+}
+
+func TestClient_SendImageFile_TooLarge(t *testing.T) {
+	var file bytes.Buffer
+	if err := png.Encode(&file, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatalf("png.Encode: %s", err)
+	}
+	// declare 100000x100000 pixels in the IHDR chunk, which follows the 8 byte signature
+	data := file.Bytes()
+	binary.BigEndian.PutUint32(data[16:], 100000)
+	binary.BigEndian.PutUint32(data[20:], 100000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+
+	var uploads int
+	var sent struct {
+		Info struct {
+			W            int    `json:"w"`
+			H            int    `json:"h"`
+			ThumbnailURL string `json:"thumbnail_url"`
+			Blurhash     string `json:"xyz.amorgan.blurhash"`
+		} `json:"info"`
+	}
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "POST" && req.URL.Path == "/_matrix/media/v3/upload" {
+			uploads++
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"content_uri":"mxc://example.org/huge"}`)),
+			}, nil
+		}
+		if req.Method == "PUT" && strings.HasPrefix(req.URL.Path, "/_matrix/client/r0/rooms/!foo:bar/send/m.room.message/") {
+			body, _ := ioutil.ReadAll(req.Body)
+			if err := json.Unmarshal(body, &sent); err != nil {
+				t.Fatalf("SendImageFile: invalid body %s", body)
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"event_id":"$image:bar"}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	if _, err := cli.SendImageFile("!foo:bar", "huge.png", bytes.NewReader(data)); err != nil {
+		t.Fatalf("SendImageFile: error, got %s", err.Error())
+	}
+	if uploads != 1 || sent.Info.ThumbnailURL != "" || sent.Info.Blurhash != "" {
+		t.Errorf("SendImageFile: got %d uploads and info %+v, want no thumbnail or blurhash", uploads, sent.Info)
+	}
+	if sent.Info.W != 100000 || sent.Info.H != 100000 {
+		t.Errorf("SendImageFile: got info %+v, want the declared dimensions", sent.Info)
+	}
+}
+
+func TestClient_SendFile(t *testing.T) {
+	var sent map[string]interface{}
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == "POST" && req.URL.Path == "/_matrix/media/v3/upload":
+			if req.Header.Get("Content-Type") != "application/pdf" {
+				t.Errorf("SendFile: got content type %s", req.Header.Get("Content-Type"))
+			}
+			ioutil.ReadAll(req.Body)
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"content_uri":"mxc://example.org/report"}`)),
+			}, nil
+		case req.Method == "PUT" && strings.HasPrefix(req.URL.Path, "/_matrix/client/r0/rooms/!foo:bar/send/m.room.message/"):
+			body, _ := ioutil.ReadAll(req.Body)
+			json.Unmarshal(body, &sent)
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"event_id":"$file:bar"}`)),
+			}, nil
+		}
+		return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+	})
+
+	// no extension: the type is sniffed from the content
+	if _, err := cli.SendFile("!foo:bar", "report", bytes.NewBufferString("%PDF-1.4 ...")); err != nil {
+		t.Fatalf("SendFile: error, got %s", err.Error())
+	}
+	info, _ := sent["info"].(map[string]interface{})
+	if sent["msgtype"] != "m.file" || sent["filename"] != "report" || sent["url"] != "mxc://example.org/report" ||
+		info["mimetype"] != "application/pdf" || info["size"] != float64(12) {
+		t.Fatalf("SendFile: got %v", sent)
+	}
+}
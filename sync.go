@@ -6,6 +6,7 @@ import (
 	"github.com/rbns/gomatrix/event"
 	"github.com/rbns/gomatrix/response"
 	"runtime/debug"
+	"sync"
 	"time"
 )
 
@@ -28,6 +29,9 @@ type DefaultSyncer struct {
 	UserID    string
 	Store     Storer
 	listeners map[string][]OnEventListener // event type to listeners array
+
+	presenceMutex sync.RWMutex              // protects presence
+	presence      map[string]event.Presence // user ID to their latest presence
 }
 
 // OnEventListener can be used with DefaultSyncer.OnEventType to be informed of incoming events.
@@ -39,22 +43,39 @@ func NewDefaultSyncer(userID string, store Storer) *DefaultSyncer {
 		UserID:    userID,
 		Store:     store,
 		listeners: make(map[string][]OnEventListener),
+		presence:  make(map[string]event.Presence),
 	}
 }
 
 // ProcessResponse processes the /sync response in a way suitable for bots. "Suitable for bots" means a stream of
 // unrepeating events. Returns a fatal error if a listener panics.
+//
+// Account data is only sent when it changes, so listeners are notified of it even for the initial sync (since=""),
+// which is the only one with all of it. The presence, typing users and read receipts in the initial sync are kept
+// for UserPresence and the rooms, but listeners are not notified of them.
 func (s *DefaultSyncer) ProcessResponse(res *response.Sync, since string) (err error) {
-	if !s.shouldProcessResponse(res, since) {
-		return
-	}
-
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("ProcessResponse panicked! userID=%s since=%s panic=%s\n%s", s.UserID, since, r, debug.Stack())
 		}
 	}()
 
+	if since == "" {
+		for _, e := range res.Presence.Events {
+			s.updatePresence(&e)
+		}
+	}
+	// before shouldProcessResponse, which drops the rooms which were just joined
+	s.processAccountData(res)
+	s.updateEphemeralState(res)
+	if !s.shouldProcessResponse(res, since) {
+		return
+	}
+
+	for _, e := range res.Presence.Events {
+		s.updatePresence(&e)
+		s.notifyListeners(&e)
+	}
 	for roomID, roomData := range res.Rooms.Join {
 		room := s.getOrCreateRoom(roomID)
 		for _, e := range roomData.State.Events {
@@ -66,6 +87,10 @@ func (s *DefaultSyncer) ProcessResponse(res *response.Sync, since string) (err e
 			e.RoomID = roomID
 			s.notifyListeners(&e)
 		}
+		for _, e := range roomData.Ephemeral.Events {
+			e.RoomID = roomID
+			s.notifyListeners(&e)
+		}
 	}
 	for roomID, roomData := range res.Rooms.Invite {
 		room := s.getOrCreateRoom(roomID)
@@ -88,6 +113,38 @@ func (s *DefaultSyncer) ProcessResponse(res *response.Sync, since string) (err e
 	return
 }
 
+// processAccountData notifies listeners of the global account data and the account data of the joined rooms, and
+// updates the tags of the rooms.
+func (s *DefaultSyncer) processAccountData(res *response.Sync) {
+	for _, e := range res.AccountData.Events {
+		s.notifyListeners(&e)
+	}
+	for roomID, roomData := range res.Rooms.Join {
+		for _, e := range roomData.AccountData.Events {
+			e.RoomID = roomID
+			if t, ok := e.Content.(event.Tag); ok {
+				s.getOrCreateRoom(roomID).Tags = t.Tags
+			}
+			s.notifyListeners(&e)
+		}
+	}
+}
+
+// updateEphemeralState updates the typing users and read receipts of the joined rooms. Listeners are notified of the
+// ephemeral events later, for incremental syncs only.
+func (s *DefaultSyncer) updateEphemeralState(res *response.Sync) {
+	for roomID, roomData := range res.Rooms.Join {
+		for _, e := range roomData.Ephemeral.Events {
+			switch c := e.Content.(type) {
+			case event.Typing:
+				s.getOrCreateRoom(roomID).Typing = c.UserIDs
+			case event.Receipt:
+				s.getOrCreateRoom(roomID).UpdateReceipts(c)
+			}
+		}
+	}
+}
+
 // OnEventType allows callers to be notified when there are new events for the given event type.
 // There are no duplicate checks.
 func (s *DefaultSyncer) OnEventType(eventType string, callback OnEventListener) {
@@ -98,6 +155,35 @@ func (s *DefaultSyncer) OnEventType(eventType string, callback OnEventListener)
 	s.listeners[eventType] = append(s.listeners[eventType], callback)
 }
 
+// UserPresence returns the latest presence of the given user seen in /sync, or nil if none has been seen.
+// It is safe to call from any goroutine.
+func (s *DefaultSyncer) UserPresence(userID string) *event.Presence {
+	s.presenceMutex.RLock()
+	defer s.presenceMutex.RUnlock()
+	p, ok := s.presence[userID]
+	if !ok {
+		return nil
+	}
+	return &p
+}
+
+func (s *DefaultSyncer) updatePresence(e *event.Event) {
+	p, ok := e.Content.(event.Presence)
+	if !ok {
+		return
+	}
+	userID := e.Sender
+	if userID == "" {
+		userID = p.UserID
+	}
+	s.presenceMutex.Lock()
+	defer s.presenceMutex.Unlock()
+	if s.presence == nil {
+		s.presence = make(map[string]event.Presence)
+	}
+	s.presence[userID] = p
+}
+
 // shouldProcessResponse returns true if the response should be processed. May modify the response to remove
 // stuff that shouldn't be processed.
 func (s *DefaultSyncer) shouldProcessResponse(resp *response.Sync, since string) bool {
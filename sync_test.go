@@ -0,0 +1,246 @@
+package gomatrix
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rbns/gomatrix/event"
+	"github.com/rbns/gomatrix/response"
+)
+
+func TestDefaultSyncer_ProcessResponsePresence(t *testing.T) {
+	var res response.Sync
+	if err := json.Unmarshal([]byte(`{"next_batch":"s2","presence":{"events":[
+		{"type":"m.presence","sender":"@alice:bar","content":{"presence":"online","status_msg":"on call","currently_active":true}}
+	]}}`), &res); err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+
+	syncer := NewDefaultSyncer("@user:bar", NewInMemoryStore())
+	var got []string
+	syncer.OnEventType("m.presence", func(ev *event.Event) {
+		got = append(got, ev.Sender)
+	})
+	if err := syncer.ProcessResponse(&res, "s1"); err != nil {
+		t.Fatalf("ProcessResponse: error, got %s", err)
+	}
+
+	if len(got) != 1 || got[0] != "@alice:bar" {
+		t.Fatalf("ProcessResponse: notified for %v, want [@alice:bar]", got)
+	}
+	p := syncer.UserPresence("@alice:bar")
+	if p == nil || p.Presence != event.PresenceOnline || p.StatusMsg != "on call" {
+		t.Fatalf("UserPresence: got %+v, want online with status message", p)
+	}
+	if syncer.UserPresence("@bob:bar") != nil {
+		t.Fatal("UserPresence: got presence for an unknown user")
+	}
+}
+
+func TestDefaultSyncer_ProcessResponseInitialPresence(t *testing.T) {
+	var res response.Sync
+	if err := json.Unmarshal([]byte(`{"next_batch":"s1","presence":{"events":[
+		{"type":"m.presence","sender":"@alice:bar","content":{"presence":"unavailable"}}
+	]}}`), &res); err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+
+	syncer := NewDefaultSyncer("@user:bar", NewInMemoryStore())
+	syncer.OnEventType("m.presence", func(ev *event.Event) {
+		t.Errorf("ProcessResponse: notified for the initial presence of %s", ev.Sender)
+	})
+	if err := syncer.ProcessResponse(&res, ""); err != nil {
+		t.Fatalf("ProcessResponse: error, got %s", err)
+	}
+
+	if p := syncer.UserPresence("@alice:bar"); p == nil || p.Presence != event.PresenceUnavailable {
+		t.Fatalf("UserPresence: got %+v, want unavailable", p)
+	}
+}
+
+func TestDefaultSyncer_ProcessResponseAccountData(t *testing.T) {
+	var res response.Sync
+	if err := json.Unmarshal([]byte(`{"next_batch":"s2",
+		"account_data":{"events":[{"type":"m.direct","content":{"@alice:bar":["!dm:bar"]}}]},
+		"rooms":{"join":{"!foo:bar":{"account_data":{"events":[{"type":"m.fully_read","content":{"event_id":"$ev:bar"}}]}}}}
+	}`), &res); err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+
+	syncer := NewDefaultSyncer("@user:bar", NewInMemoryStore())
+	var direct event.Direct
+	var fullyRead *event.Event
+	syncer.OnEventType("m.direct", func(ev *event.Event) {
+		direct, _ = ev.Content.(event.Direct)
+	})
+	syncer.OnEventType("m.fully_read", func(ev *event.Event) {
+		fullyRead = ev
+	})
+	for _, since := range []string{"", "s1"} {
+		direct, fullyRead = nil, nil
+		if err := syncer.ProcessResponse(&res, since); err != nil {
+			t.Fatalf("ProcessResponse(since=%q): error, got %s", since, err)
+		}
+
+		if len(direct["@alice:bar"]) != 1 || direct["@alice:bar"][0] != "!dm:bar" {
+			t.Fatalf("ProcessResponse(since=%q): got m.direct %v", since, direct)
+		}
+		if fullyRead == nil || fullyRead.RoomID != "!foo:bar" || fullyRead.Content.(event.FullyRead).EventID != "$ev:bar" {
+			t.Fatalf("ProcessResponse(since=%q): got m.fully_read %+v", since, fullyRead)
+		}
+	}
+}
+
+func TestDefaultSyncer_ProcessResponseTags(t *testing.T) {
+	var res response.Sync
+	if err := json.Unmarshal([]byte(`{"next_batch":"s2","rooms":{"join":{"!foo:bar":{"account_data":{"events":[
+		{"type":"m.tag","content":{"tags":{"m.favourite":{"order":0.5},"u.work":{}}}}
+	]}}}}}`), &res); err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+
+	for _, since := range []string{"", "s1"} {
+		store := NewInMemoryStore()
+		syncer := NewDefaultSyncer("@user:bar", store)
+		if err := syncer.ProcessResponse(&res, since); err != nil {
+			t.Fatalf("ProcessResponse(since=%q): error, got %s", since, err)
+		}
+
+		room := store.LoadRoom("!foo:bar")
+		if room == nil || !room.HasTag(event.TagFavourite) || !room.HasTag("u.work") || room.HasTag(event.TagLowPriority) {
+			t.Fatalf("ProcessResponse(since=%q): got room %+v", since, room)
+		}
+		if order := room.Tags[event.TagFavourite].Order; order == nil || *order != 0.5 {
+			t.Fatalf("ProcessResponse(since=%q): got m.favourite order %v, want 0.5", since, order)
+		}
+	}
+}
+
+func TestDefaultSyncer_ProcessResponseTagsOnJoin(t *testing.T) {
+	var res response.Sync
+	if err := json.Unmarshal([]byte(`{"next_batch":"s2","rooms":{"join":{"!foo:bar":{
+		"timeline":{"events":[{"type":"m.room.member","state_key":"@user:bar","sender":"@user:bar","content":{"membership":"join"}}]},
+		"account_data":{"events":[{"type":"m.tag","content":{"tags":{"m.lowpriority":{}}}}]}
+	}}}}`), &res); err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+
+	store := NewInMemoryStore()
+	syncer := NewDefaultSyncer("@user:bar", store)
+	if err := syncer.ProcessResponse(&res, "s1"); err != nil {
+		t.Fatalf("ProcessResponse: error, got %s", err)
+	}
+
+	if room := store.LoadRoom("!foo:bar"); room == nil || !room.HasTag(event.TagLowPriority) {
+		t.Fatalf("ProcessResponse: got room %+v, want the tags of the joined room", room)
+	}
+}
+
+func TestDefaultSyncer_ProcessResponseReceipts(t *testing.T) {
+	var res response.Sync
+	if err := json.Unmarshal([]byte(`{"next_batch":"s2","rooms":{"join":{"!foo:bar":{"ephemeral":{"events":[
+		{"type":"m.receipt","content":{
+			"$old:bar":{"m.read":{"@alice:bar":{"ts":100},"@bob:bar":{"ts":100}}},
+			"$new:bar":{"m.read":{"@alice:bar":{"ts":200}},"m.read.private":{"@user:bar":{"ts":200}}},
+			"$thread:bar":{"m.read":{"@bob:bar":{"ts":300,"thread_id":"$root:bar"}}}
+		}}
+	]}}}}}`), &res); err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+
+	for _, since := range []string{"", "s1"} {
+		store := NewInMemoryStore()
+		syncer := NewDefaultSyncer("@user:bar", store)
+		if err := syncer.ProcessResponse(&res, since); err != nil {
+			t.Fatalf("ProcessResponse(since=%q): error, got %s", since, err)
+		}
+
+		room := store.LoadRoom("!foo:bar")
+		if room == nil {
+			t.Fatalf("ProcessResponse(since=%q): no room", since)
+		}
+		for userID, want := range map[string]string{"@alice:bar": "$new:bar", "@bob:bar": "$old:bar", "@user:bar": "$new:bar"} {
+			if got := room.ReadUpTo(userID); got != want {
+				t.Errorf("ReadUpTo(%s) (since=%q): got %s, want %s", userID, since, got, want)
+			}
+		}
+		if readBy := room.ReadBy("$old:bar"); len(readBy) != 1 || readBy[0] != "@bob:bar" {
+			t.Errorf("ReadBy (since=%q): got %v, want [@bob:bar]", since, readBy)
+		}
+	}
+}
+
+func TestDefaultSyncer_ProcessResponseEphemeral(t *testing.T) {
+	var res response.Sync
+	if err := json.Unmarshal([]byte(`{"next_batch":"s2","rooms":{"join":{"!foo:bar":{"ephemeral":{"events":[
+		{"type":"m.typing","content":{"user_ids":["@alice:bar"]}},
+		{"type":"m.receipt","content":{"$event:bar":{"m.read":{"@bob:bar":{"ts":100}}}}}
+	]}}}}}`), &res); err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+
+	store := NewInMemoryStore()
+	syncer := NewDefaultSyncer("@user:bar", store)
+	var roomIDs []string
+	for _, eventType := range []string{"m.typing", "m.receipt"} {
+		syncer.OnEventType(eventType, func(e *event.Event) {
+			roomIDs = append(roomIDs, e.RoomID)
+		})
+	}
+	if err := syncer.ProcessResponse(&res, "s1"); err != nil {
+		t.Fatalf("ProcessResponse: error, got %s", err)
+	}
+
+	if len(roomIDs) != 2 || roomIDs[0] != "!foo:bar" || roomIDs[1] != "!foo:bar" {
+		t.Fatalf("ProcessResponse: got ephemeral events for rooms %v", roomIDs)
+	}
+	room := store.LoadRoom("!foo:bar")
+	if !room.IsTyping("@alice:bar") || room.IsTyping("@bob:bar") {
+		t.Errorf("ProcessResponse: got typing users %v, want [@alice:bar]", room.Typing)
+	}
+	if got := room.ReadUpTo("@bob:bar"); got != "$event:bar" {
+		t.Errorf("ProcessResponse: got receipt for %s, want $event:bar", got)
+	}
+}
+
+func TestDefaultSyncer_ProcessResponseInitialTyping(t *testing.T) {
+	var res response.Sync
+	if err := json.Unmarshal([]byte(`{"next_batch":"s1","rooms":{"join":{"!foo:bar":{"ephemeral":{"events":[
+		{"type":"m.typing","content":{"user_ids":["@alice:bar"]}}
+	]}}}}}`), &res); err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+
+	store := NewInMemoryStore()
+	syncer := NewDefaultSyncer("@user:bar", store)
+	syncer.OnEventType("m.typing", func(e *event.Event) {
+		t.Errorf("ProcessResponse: notified for the initial typing users of %s", e.RoomID)
+	})
+	if err := syncer.ProcessResponse(&res, ""); err != nil {
+		t.Fatalf("ProcessResponse: error, got %s", err)
+	}
+
+	if room := store.LoadRoom("!foo:bar"); room == nil || !room.IsTyping("@alice:bar") {
+		t.Fatalf("ProcessResponse: got room %+v, want @alice:bar typing", room)
+	}
+}
+
+func TestDefaultSyncer_ProcessResponseTypingOnJoin(t *testing.T) {
+	var res response.Sync
+	if err := json.Unmarshal([]byte(`{"next_batch":"s2","rooms":{"join":{"!foo:bar":{
+		"timeline":{"events":[{"type":"m.room.member","state_key":"@user:bar","sender":"@user:bar","content":{"membership":"join"}}]},
+		"ephemeral":{"events":[{"type":"m.typing","content":{"user_ids":["@alice:bar"]}}]}
+	}}}}`), &res); err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+
+	store := NewInMemoryStore()
+	syncer := NewDefaultSyncer("@user:bar", store)
+	if err := syncer.ProcessResponse(&res, "s1"); err != nil {
+		t.Fatalf("ProcessResponse: error, got %s", err)
+	}
+
+	if room := store.LoadRoom("!foo:bar"); room == nil || !room.IsTyping("@alice:bar") {
+		t.Fatalf("ProcessResponse: got room %+v, want the typing users of the joined room", room)
+	}
+}
@@ -0,0 +1,192 @@
+package gomatrix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/rbns/gomatrix/request"
+	"github.com/rbns/gomatrix/response"
+)
+
+// UIAStageHandler completes a single stage of user-interactive authentication. It is given the current state of the
+// authentication session, including the stage's params (see response.UserInteractive.Params), and returns the auth
+// data to submit for the stage. The Type and Session of the returned auth data are filled in by the UIA.
+type UIAStageHandler func(ctx context.Context, state *response.UserInteractive) (*request.AuthData, error)
+
+// UIA drives user-interactive authentication for endpoints which require it, such as registering, deleting devices or
+// changing the password. See https://spec.matrix.org/latest/client-server-api/#user-interactive-authentication-api
+//
+// A handler is registered for every stage the application is able to complete. When the homeserver asks for
+// authentication, the UIA picks the flow which needs the fewest stages out of those it has handlers for, and completes
+// the stages of that flow in turn until the request succeeds.
+//
+//	uia := gomatrix.NewUIA()
+//	uia.Handle(request.AuthTypePassword, gomatrix.PasswordStage(request.NewUserIdentifier(cli.UserID), "wonderland"))
+//	err := cli.DeleteDevice("ABCDEFGH", uia)
+type UIA struct {
+	handlers map[string]UIAStageHandler
+}
+
+// NewUIA returns a UIA without any stage handlers.
+func NewUIA() *UIA {
+	return &UIA{
+		handlers: make(map[string]UIAStageHandler),
+	}
+}
+
+// Handle registers the handler for the given stage, e.g. request.AuthTypePassword. It replaces any existing handler
+// for the stage.
+func (u *UIA) Handle(stage string, handler UIAStageHandler) {
+	u.handlers[stage] = handler
+}
+
+// nextStage returns the next stage to complete, from the flow with the fewest stages left which can be completed
+// with the registered handlers.
+func (u *UIA) nextStage(state *response.UserInteractive) (string, bool) {
+	var next string
+	best := -1
+FLOWS:
+	for _, flow := range state.Flows {
+		var remaining []string
+		for _, stage := range flow.Stages {
+			if state.HasCompleted(stage) {
+				continue
+			}
+			if _, ok := u.handlers[stage]; !ok {
+				continue FLOWS
+			}
+			remaining = append(remaining, stage)
+		}
+		if len(remaining) > 0 && (best == -1 || len(remaining) < best) {
+			next = remaining[0]
+			best = len(remaining)
+		}
+	}
+	return next, best != -1
+}
+
+// MakeUIARequest makes a JSON HTTP request like MakeRequest to an endpoint which may require user-interactive
+// authentication. body is called before each attempt to build the request body, and is given the auth data to send
+// with it, which is nil for the first attempt.
+//
+// If the homeserver asks for authentication, the stages are completed with uia and the request is retried. If uia is
+// nil, or the authentication fails, the HTTPError for the last attempt is returned. Its HTTP body can be decoded as a
+// response.UserInteractive.
+func (cli *Client) MakeUIARequest(method, httpURL string, uia *UIA, body func(auth *request.AuthData) interface{}, resBody interface{}) ([]byte, error) {
+	return cli.MakeUIARequestContext(context.Background(), method, httpURL, uia, body, resBody)
+}
+
+// MakeUIARequestContext is like MakeUIARequest, but the requests are bound to ctx.
+func (cli *Client) MakeUIARequestContext(ctx context.Context, method, httpURL string, uia *UIA, body func(auth *request.AuthData) interface{}, resBody interface{}) ([]byte, error) {
+	var auth *request.AuthData
+	for {
+		contents, err := cli.MakeRequestContext(ctx, method, httpURL, body(auth), resBody)
+		var httpErr HTTPError
+		if err == nil || uia == nil || !errors.As(err, &httpErr) || httpErr.Code != 401 {
+			return contents, err
+		}
+		var state response.UserInteractive
+		if json.Unmarshal(contents, &state) != nil || len(state.Flows) == 0 {
+			// a plain 401 without any flows: the access token is bad
+			return contents, err
+		}
+		if auth != nil && !state.HasCompleted(auth.Type) {
+			// the stage we just submitted was rejected
+			return contents, err
+		}
+
+		stage, ok := uia.nextStage(&state)
+		if !ok {
+			return contents, fmt.Errorf("no supported user-interactive authentication flow: %w", err)
+		}
+		auth, err = uia.handlers[stage](ctx, &state)
+		if err != nil {
+			return nil, err
+		}
+		if auth == nil {
+			return nil, fmt.Errorf("uia: handler for %s returned no auth", stage)
+		}
+		auth.Type = stage
+		auth.Session = state.Session
+	}
+}
+
+// PasswordStage returns a UIAStageHandler for m.login.password.
+func PasswordStage(identifier *request.UserIdentifier, password string) UIAStageHandler {
+	return func(ctx context.Context, state *response.UserInteractive) (*request.AuthData, error) {
+		return &request.AuthData{
+			Identifier: identifier,
+			Password:   password,
+		}, nil
+	}
+}
+
+// DummyStage returns a UIAStageHandler for m.login.dummy.
+func DummyStage() UIAStageHandler {
+	return func(ctx context.Context, state *response.UserInteractive) (*request.AuthData, error) {
+		return &request.AuthData{}, nil
+	}
+}
+
+// RecaptchaStage returns a UIAStageHandler for m.login.recaptcha. solve is given the site's public key and must
+// return the response from the user solving the CAPTCHA.
+func RecaptchaStage(solve func(ctx context.Context, publicKey string) (string, error)) UIAStageHandler {
+	return func(ctx context.Context, state *response.UserInteractive) (*request.AuthData, error) {
+		var params struct {
+			PublicKey string `json:"public_key"`
+		}
+		if err := stageParams(state, request.AuthTypeRecaptcha, &params); err != nil {
+			return nil, err
+		}
+		res, err := solve(ctx, params.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		return &request.AuthData{Response: res}, nil
+	}
+}
+
+// ThreePIDStage returns a UIAStageHandler for m.login.email.identity or m.login.msisdn. validate must make sure the
+// user has validated their 3PID, e.g. by waiting for them to click the link in the email they were sent, and return
+// the credentials for the validation session.
+func ThreePIDStage(validate func(ctx context.Context) (*request.ThreePIDCredentials, error)) UIAStageHandler {
+	return func(ctx context.Context, state *response.UserInteractive) (*request.AuthData, error) {
+		creds, err := validate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &request.AuthData{ThreePIDCreds: creds}, nil
+	}
+}
+
+// TermsStage returns a UIAStageHandler for m.login.terms. accept is given the policies the user must agree to, keyed
+// by policy name, and must return an error if the user does not accept them.
+func TermsStage(accept func(ctx context.Context, policies map[string]response.TermsPolicy) error) UIAStageHandler {
+	return func(ctx context.Context, state *response.UserInteractive) (*request.AuthData, error) {
+		var params struct {
+			Policies map[string]response.TermsPolicy `json:"policies"`
+		}
+		if err := stageParams(state, request.AuthTypeTerms, &params); err != nil {
+			return nil, err
+		}
+		if err := accept(ctx, params.Policies); err != nil {
+			return nil, err
+		}
+		return &request.AuthData{}, nil
+	}
+}
+
+// stageParams decodes the params for the given stage into out.
+func stageParams(state *response.UserInteractive, stage string, out interface{}) error {
+	params, ok := state.Params[stage]
+	if !ok {
+		return nil
+	}
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,108 @@
+package gomatrix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/rbns/gomatrix/request"
+	"github.com/rbns/gomatrix/response"
+)
+
+func TestClient_RegisterWithUIA(t *testing.T) {
+	var stages []string
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method != "POST" || req.URL.Path != "/_matrix/client/r0/register" {
+			return nil, fmt.Errorf("unhandled URL: %s", req.URL.Path)
+		}
+		var body struct {
+			Auth *request.AuthData `json:"auth"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+		if body.Auth != nil {
+			if body.Auth.Session != "sess" {
+				t.Errorf("RegisterWithUIA: got session %q, want sess", body.Auth.Session)
+			}
+			stages = append(stages, body.Auth.Type)
+		}
+		uiaBody := `{"session":"sess","flows":[{"stages":["m.login.email.identity"]},{"stages":["m.login.recaptcha","m.login.dummy"]}],` +
+			`"params":{"m.login.recaptcha":{"public_key":"pubkey"}},"completed":%s}`
+		switch len(stages) {
+		case 0:
+			return &http.Response{
+				StatusCode: 401,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(fmt.Sprintf(uiaBody, `[]`))),
+			}, nil
+		case 1:
+			if body.Auth.Response != "solved:pubkey" {
+				t.Errorf("RegisterWithUIA: got recaptcha response %q, want solved:pubkey", body.Auth.Response)
+			}
+			return &http.Response{
+				StatusCode: 401,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(fmt.Sprintf(uiaBody, `["m.login.recaptcha"]`))),
+			}, nil
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{"user_id":"@alice:test.gomatrix.org","access_token":"abc"}`)),
+		}, nil
+	})
+
+	uia := NewUIA()
+	uia.Handle(request.AuthTypeDummy, DummyStage())
+	uia.Handle(request.AuthTypeRecaptcha, RecaptchaStage(func(ctx context.Context, publicKey string) (string, error) {
+		return "solved:" + publicKey, nil
+	}))
+	req := &request.Register{Username: "alice", Password: "wonderland"}
+	resp, err := cli.RegisterWithUIA(req, uia)
+	if err != nil {
+		t.Fatalf("RegisterWithUIA: error, got %s", err.Error())
+	}
+	if req.Auth != nil {
+		t.Errorf("RegisterWithUIA: modified the caller's request, got auth %+v", req.Auth)
+	}
+	if resp.AccessToken != "abc" {
+		t.Fatalf("RegisterWithUIA: got access token %s, want abc", resp.AccessToken)
+	}
+	if len(stages) != 2 || stages[0] != request.AuthTypeRecaptcha || stages[1] != request.AuthTypeDummy {
+		t.Fatalf("RegisterWithUIA: completed stages %v, want [m.login.recaptcha m.login.dummy]", stages)
+	}
+}
+
+func TestClient_RegisterWithUIA_NoSupportedFlow(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 401,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{"session":"sess","flows":[{"stages":["m.login.email.identity"]}]}`)),
+		}, nil
+	})
+
+	uia := NewUIA()
+	uia.Handle(request.AuthTypeDummy, DummyStage())
+	if _, err := cli.RegisterWithUIA(&request.Register{Username: "alice"}, uia); err == nil {
+		t.Fatal("RegisterWithUIA: got nil error, want an error")
+	}
+}
+
+func TestClient_RegisterWithUIA_NilAuth(t *testing.T) {
+	cli := mockClient(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 401,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{"session":"sess","flows":[{"stages":["m.login.dummy"]}]}`)),
+		}, nil
+	})
+
+	uia := NewUIA()
+	uia.Handle(request.AuthTypeDummy, func(ctx context.Context, state *response.UserInteractive) (*request.AuthData, error) {
+		return nil, nil
+	})
+	if _, err := cli.RegisterWithUIA(&request.Register{Username: "alice"}, uia); err == nil {
+		t.Fatal("RegisterWithUIA: got no error for a handler which returned no auth")
+	}
+}
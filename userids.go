@@ -128,3 +128,13 @@ func ExtractUserLocalpart(userID string) (string, error) {
 		"@", // remove "@" prefix
 	), nil
 }
+
+// ExtractUserServerName extracts the server name portion of a user ID, including the port if there is one.
+// See http://matrix.org/docs/spec/intro.html#user-identifiers
+func ExtractUserServerName(userID string) (string, error) {
+	parts := strings.SplitN(userID, ":", 2) // @foo:bar:8448 => [ "@foo", "bar:8448" ]
+	if len(userID) == 0 || userID[0] != '@' || len(parts) != 2 || parts[1] == "" {
+		return "", fmt.Errorf("%s is not a valid user id", userID)
+	}
+	return parts[1], nil
+}
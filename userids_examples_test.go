@@ -25,3 +25,12 @@ func ExampleExtractUserLocalpart() {
 	fmt.Println(localpart)
 	// Output: alice
 }
+
+func ExampleExtractUserServerName() {
+	serverName, err := ExtractUserServerName("@alice:matrix.org")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(serverName)
+	// Output: matrix.org
+}
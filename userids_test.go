@@ -84,3 +84,31 @@ func TestExtractUserLocalpart(t *testing.T) {
 		}
 	}
 }
+
+var servernametests = []struct {
+	Input        string
+	ExpectOutput string
+}{
+	{"@foo:bar", "bar"},
+	{"@foo:bar:8448", "bar:8448"},
+	{"@foo.bar:baz.quuz", "baz.quuz"},
+	{"@foo:[::1]:8448", "[::1]:8448"},
+}
+
+func TestExtractUserServerName(t *testing.T) {
+	for _, u := range servernametests {
+		out, err := ExtractUserServerName(u.Input)
+		if err != nil {
+			t.Errorf("TestExtractUserServerName(%s) => Error: %s", u.Input, err)
+			continue
+		}
+		if out != u.ExpectOutput {
+			t.Errorf("TestExtractUserServerName(%s) => Got: %s, Want %s", u.Input, out, u.ExpectOutput)
+		}
+	}
+	for _, in := range []string{"", "foo:bar", "@foo", "@foo:"} {
+		if _, err := ExtractUserServerName(in); err == nil {
+			t.Errorf("TestExtractUserServerName(%s) => Got: nil error Expected: error", in)
+		}
+	}
+}